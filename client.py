@@ -7,12 +7,27 @@ import argparse
 import sys
 
 
+# 需要回复的提示类型，回复被拒绝时按最近一次提示重新作答
+PROMPT_TYPES = {
+    "sheriff_signup", "sheriff_withdraw", "sheriff_election", "day_vote", "pk_vote", "night_action",
+    "hunter_shoot", "badge_transfer", "speech_direction", "wolf_king_target",
+}
+
+
 class WerewolfClient:
-    def __init__(self, host='localhost', port=5001, player_name=None):
+    def __init__(self, host='localhost', port=5001, player_name=None, locale="zh-CN", spectate=False, god_token=None):
         """初始化狼人杀客户端"""
         self.host = host
         self.port = port
         self.player_name = player_name or f"Player_{random.randint(1000, 9999)}"
+        self.locale = locale
+        self.spectate = spectate
+        self.god_token = god_token
+        self.catalog = {"roles": {}, "factions": {}, "checks": {}}
+        self.player_id = None
+        self.seat_names = {}
+        self.knowledge = {}
+        self.last_prompt = None
         self.socket = None
         self.running = False
         self.game_state = {
@@ -23,12 +38,36 @@ class WerewolfClient:
             "is_sheriff": False
         }
         self.action_handlers = {
+            "catalog": self.handle_catalog,
             "wait_confirm": self.handle_wait_confirm,
             "game_status": self.handle_game_status,
+            "spectate": self.handle_spectate,
+            "event": self.handle_event,
+            "join_rejected": self.handle_join_rejected,
+            "sheriff_signup": self.handle_sheriff_signup,
+            "sheriff_candidates": self.handle_sheriff_candidates,
+            "sheriff_withdraw": self.handle_sheriff_withdraw,
+            "sheriff_withdrawn": self.handle_sheriff_withdrawn,
             "sheriff_election": self.handle_sheriff_election,
+            "sheriff_result": self.handle_sheriff_result,
             "night_action": self.handle_night_action,
+            "wolf_chat": self.handle_wolf_chat,
+            "wolf_vote_update": self.handle_wolf_vote_update,
             "seer_result": self.handle_seer_result,
+            "lovers": self.handle_lovers,
             "day_vote": self.handle_day_vote,
+            "pk_start": self.handle_pk_start,
+            "pk_vote": self.handle_pk_vote,
+            "speech_direction": self.handle_speech_direction,
+            "speech_turn": self.handle_speech_turn,
+            "speech": self.handle_speech,
+            "last_words": self.handle_last_words,
+            "last_words_speech": self.handle_last_words_speech,
+            "hunter_shoot": self.handle_hunter_shoot,
+            "badge_transfer": self.handle_badge_transfer,
+            "wolf_king_target": self.handle_wolf_king_target,
+            "my_knowledge": self.handle_my_knowledge,
+            "action_rejected": self.handle_action_rejected,
             "game_end": self.handle_game_end
         }
         self.action_callback = None
@@ -42,8 +81,13 @@ class WerewolfClient:
             self.socket.connect((self.host, self.port))
             self.connection_status = "已连接"
 
-            # 发送玩家名称
-            self.send_message({"name": self.player_name})
+            # 发送玩家名称和显示语言，观战者附带上帝视角令牌
+            join = {"name": self.player_name, "locale": self.locale}
+            if self.spectate:
+                join["spectate"] = True
+                if self.god_token:
+                    join["god_token"] = self.god_token
+            self.send_message(join)
 
             # 启动消息接收线程
             self.running = True
@@ -153,11 +197,22 @@ class WerewolfClient:
         self.log(f"收到消息: {message_type}")
 
         if message_type in self.action_handlers:
+            if message_type in PROMPT_TYPES:
+                self.last_prompt = message
             self.action_handlers[message_type](message)
         else:
             self.log(f"未知消息类型: {message_type}")
 
     # 以下是各种消息处理函数
+    def handle_catalog(self, message):
+        """保存服务器下发的角色、阵营和查验结果的显示名称"""
+        for section in self.catalog:
+            self.catalog[section] = message.get(section, {})
+
+    def role_name(self, role_id):
+        """将角色ID转换为当前语言的显示名称"""
+        return self.catalog["roles"].get(role_id, role_id)
+
     def handle_wait_confirm(self, message):
         """处理等待确认消息"""
         players = message.get("players", [])
@@ -171,21 +226,120 @@ class WerewolfClient:
         self.game_state["role"] = message.get("role")
         self.game_state["players"] = message.get("players", [])
         self.game_state["day_count"] = message.get("day_count", 0)
+        self.knowledge = message.get("knowledge", self.knowledge)
+
+        # 协议中使用座位ID标识玩家，显示名称可能被服务器去重
+        seats = message.get("seats", [])
+        self.seat_names = {seat["id"]: seat["name"] for seat in seats}
+        if message.get("spectator"):
+            self.log(f"观战状态更新: 视角={message.get('view')}, 天数={self.game_state['day_count']}")
+            if message.get("lovers"):
+                self.log(f"情侣: {self.display(message['lovers'])}")
+            self.log_player_status()
+            return
+        self.player_id = message.get("player_id", self.player_id)
+        if self.player_id in self.seat_names:
+            self.player_name = self.seat_names[self.player_id]
 
         # 更新自己的状态
-        for player in self.game_state["players"]:
-            if player[0] == self.player_name:
+        for seat, player in zip(seats, self.game_state["players"]):
+            if seat["id"] == self.player_id:
                 self.game_state["alive"] = player[2]
                 self.game_state["is_sheriff"] = player[3]
                 break
 
-        self.log(f"游戏状态更新: 角色={self.game_state['role']}, 天数={self.game_state['day_count']}")
+        self.log(f"游戏状态更新: 角色={self.role_name(self.game_state['role'])}, 天数={self.game_state['day_count']}")
         self.log_player_status()
 
+    def handle_spectate(self, message):
+        """处理观战确认，回放加入前已发生的事件"""
+        self.seat_names = {seat["id"]: seat["name"] for seat in message.get("seats", [])}
+        self.log(f"以{'上帝' if message.get('view') == 'god' else '公开'}视角观战")
+        for event in message.get("events", []):
+            self.handle_event({"event": event})
+
+    def handle_event(self, message):
+        """处理观战者收到的游戏事件"""
+        event = message.get("event", {})
+        text = f"第{event.get('day')}天 [{event.get('visibility')}] {event.get('type')}"
+        if event.get("actor"):
+            text += f" {self.display(event['actor'])}"
+        if event.get("target"):
+            text += f" -> {self.display(event['target'])}"
+        if event.get("data"):
+            text += f" {json.dumps(event['data'], ensure_ascii=False)}"
+        self.log(text)
+
+    def handle_join_rejected(self, message):
+        """处理加入被拒绝"""
+        self.log(f"加入被拒绝: {message.get('reason')}")
+        self.running = False
+
+    def handle_action_rejected(self, message):
+        """处理被服务器拒绝的行动，在截止时间前重新做出选择"""
+        if message.get("prompt") == "explode":
+            self.log(f"自爆被拒绝: {message.get('reason')}")
+            return
+        self.log(f"行动被拒绝: {message.get('reason')}，剩余 {message.get('seconds_left')} 秒")
+        if self.last_prompt and message.get("prompt") == self.last_prompt.get("type"):
+            self.action_handlers[self.last_prompt["type"]](self.last_prompt)
+
+    def request_knowledge(self):
+        """向服务器查询自己掌握的信息"""
+        self.send_message({"type": "my_knowledge"})
+
+    def handle_my_knowledge(self, message):
+        """处理知识查询结果"""
+        self.knowledge = message.get("knowledge", {})
+        for target, result in self.knowledge.get("checks", {}).items():
+            self.log(f"已查验: {self.display(target)} 是 {self.catalog['checks'].get(result, result)}")
+        for target, role in self.knowledge.get("roles", {}).items():
+            if target != self.player_id:
+                self.log(f"已知身份: {self.display(target)} 是 {self.role_name(role)}")
+
+    def display(self, ids):
+        """将玩家ID转换为便于阅读的 "ID号 名称" 形式"""
+        if isinstance(ids, str):
+            return f"{ids}号 {self.seat_names.get(ids, ids)}"
+        return ', '.join(self.display(i) for i in ids)
+
+    def handle_sheriff_signup(self, message):
+        """处理警长竞选报名"""
+        if self.action_callback:
+            run = self.action_callback("sheriff_signup", [])
+        else:
+            run = random.random() < 0.5
+
+        self.log("上警" if run else "不上警")
+        self.send_message({"run": bool(run)})
+
+    def handle_sheriff_candidates(self, message):
+        """处理上警名单"""
+        self.log(f"上警玩家: {self.display(message.get('candidates', []))}")
+
+    def handle_sheriff_withdraw(self, message):
+        """处理候选人发言后的退水选择"""
+        if self.action_callback:
+            withdraw = self.action_callback("sheriff_withdraw", message.get("candidates", []))
+        else:
+            withdraw = random.random() < 0.2
+
+        self.log("退水" if withdraw else "继续竞选")
+        self.send_message({"withdraw": bool(withdraw)})
+
+    def handle_sheriff_withdrawn(self, message):
+        """处理候选人退水"""
+        self.log(f"{self.display(message.get('player'))} 退水")
+
+    def handle_sheriff_result(self, message):
+        """处理警长竞选结果"""
+        sheriff = message.get("sheriff")
+        self.log(f"{self.display(sheriff)} 当选警长" if sheriff else "警徽流失")
+
     def handle_sheriff_election(self, message):
         """处理警长选举"""
         candidates = message.get("candidates", [])
-        self.log(f"警长选举，候选人: {', '.join(candidates)}")
+        self.log(f"警长选举，候选人: {self.display(candidates)}")
 
         # 如果设置了回调，使用回调处理选举
         if self.action_callback:
@@ -195,7 +349,7 @@ class WerewolfClient:
             vote = random.choice(candidates) if candidates else None
 
         if vote:
-            self.log(f"投票给 {vote} 当警长")
+            self.log(f"投票给 {self.display(vote)} 当警长")
             self.send_message({"vote": vote})
 
     def handle_night_action(self, message):
@@ -205,7 +359,8 @@ class WerewolfClient:
         if action == "werewolf":
             # 狼人行动
             candidates = message.get("candidates", [])
-            self.log(f"请选择击杀目标: {', '.join(candidates)}")
+            self.log(f"狼队友: {self.display(message.get('teammates', []))}，商量时间 {message.get('seconds')} 秒")
+            self.log(f"请选择击杀目标: {self.display(candidates)}")
 
             if self.action_callback:
                 target = self.action_callback("werewolf", candidates)
@@ -213,7 +368,7 @@ class WerewolfClient:
                 target = random.choice(candidates) if candidates else None
 
             if target:
-                self.log(f"选择击杀 {target}")
+                self.log(f"选择击杀 {self.display(target)}")
                 self.send_message({"target": target})
 
         elif action == "witch":
@@ -225,7 +380,7 @@ class WerewolfClient:
 
             self.log(f"女巫行动 - 解药: {has_antidote}, 毒药: {has_poison}")
             if dead_players and dead_players[0]:
-                self.log(f"今晚死亡: {dead_players[0]}")
+                self.log(f"今晚死亡: {self.display(dead_players[0])}")
 
             response = {}
 
@@ -237,7 +392,7 @@ class WerewolfClient:
                     save = random.choice([True, False])
 
                 if save:
-                    self.log(f"使用解药救 {dead_players[0]}")
+                    self.log(f"使用解药救 {self.display(dead_players[0])}")
                     response["save"] = dead_players[0]
 
             # 处理毒药
@@ -248,7 +403,7 @@ class WerewolfClient:
                     poison_target = None if random.random() > 0.3 else random.choice(alive_players)
 
                 if poison_target:
-                    self.log(f"使用毒药毒 {poison_target}")
+                    self.log(f"使用毒药毒 {self.display(poison_target)}")
                     response["poison"] = poison_target
 
             self.send_message(response)
@@ -256,7 +411,7 @@ class WerewolfClient:
         elif action == "seer":
             # 预言家行动
             candidates = message.get("candidates", [])
-            self.log(f"预言家请选择查验目标: {', '.join(candidates)}")
+            self.log(f"预言家请选择查验目标: {self.display(candidates)}")
 
             if self.action_callback:
                 target = self.action_callback("seer", candidates)
@@ -264,21 +419,77 @@ class WerewolfClient:
                 target = random.choice(candidates) if candidates else None
 
             if target:
-                self.log(f"选择查验 {target}")
+                self.log(f"选择查验 {self.display(target)}")
                 self.send_message({"target": target})
 
+        elif action == "cupid":
+            # 丘比特行动，连接两名玩家成为情侣
+            candidates = message.get("candidates", [])
+            self.log(f"丘比特请选择两名情侣: {self.display(candidates)}")
+
+            if self.action_callback:
+                targets = self.action_callback("cupid", candidates)
+            else:
+                targets = random.sample(candidates, 2) if len(candidates) >= 2 else None
+
+            if targets:
+                self.log(f"连接情侣 {self.display(targets)}")
+                self.send_message({"targets": targets})
+
+        elif action == "guard":
+            # 守卫行动，不能连续两晚守护同一人
+            candidates = message.get("candidates", [])
+            if message.get("last_protected"):
+                self.log(f"昨晚守护了 {self.display(message['last_protected'])}，今晚不能再守护")
+            self.log(f"守卫请选择守护目标: {self.display(candidates)}")
+
+            if self.action_callback:
+                target = self.action_callback("guard", candidates)
+            else:
+                target = random.choice(candidates) if candidates else None
+
+            if target:
+                self.log(f"选择守护 {self.display(target)}")
+                self.send_message({"target": target})
+            else:
+                self.log("选择空守")
+                self.send_message({})
+
+    def send_wolf_chat(self, text):
+        """在狼人夜间频道中发送私聊，只有存活的狼人能收到"""
+        self.send_message({"type": "wolf_chat", "text": text})
+
+    def handle_wolf_chat(self, message):
+        """处理狼队友的夜间私聊"""
+        self.log(f"[狼人频道] {self.display(message.get('from'))}: {message.get('text')}")
+
+    def handle_wolf_vote_update(self, message):
+        """处理狼队的实时投票，可以在频道关闭前改票"""
+        target = message.get("target")
+        if message.get("final"):
+            self.log(f"狼队最终击杀: {self.display(target) if target else '空刀'}")
+        else:
+            self.log(f"{self.display(message.get('wolf'))} 选择击杀 {self.display(target)}")
+
     def handle_seer_result(self, message):
         """处理预言家查验结果"""
         target = message.get("target")
         result = message.get("result")
 
         if target and result:
-            self.log(f"查验结果: {target} 是 {result}")
+            self.log(f"查验结果: {message.get('text') or self.display(target) + ' 是 ' + result}")
+
+    def handle_lovers(self, message):
+        """处理被丘比特连接成情侣的通知"""
+        partner = message.get("partner")
+        self.log(f"你和 {self.display(partner)} ({self.role_name(message.get('partner_role'))}) 成为了情侣")
+        if message.get("third_faction"):
+            self.log("你们是人狼恋，与丘比特组成第三方阵营")
 
     def handle_day_vote(self, message):
         """处理白天投票"""
         candidates = message.get("candidates", [])
-        self.log(f"请投票出局: {', '.join(candidates)}")
+        self.log(f"请投票出局: {self.display(candidates)}")
 
         if self.action_callback:
             vote = self.action_callback("day_vote", candidates)
@@ -286,13 +497,149 @@ class WerewolfClient:
             vote = random.choice(candidates) if candidates else None
 
         if vote:
-            self.log(f"投票给 {vote}")
+            self.log(f"投票给 {self.display(vote)}")
             self.send_message({"vote": vote})
 
+    def handle_pk_start(self, message):
+        """处理平票进入PK"""
+        self.log(f"平票，{self.display(message.get('candidates', []))} 进入PK")
+
+    def handle_pk_vote(self, message):
+        """处理PK投票，只能投给PK中的玩家"""
+        candidates = message.get("candidates", [])
+        self.log(f"PK投票，请投票出局: {self.display(candidates)}")
+
+        if self.action_callback:
+            vote = self.action_callback("pk_vote", candidates)
+        else:
+            vote = random.choice(candidates) if candidates else None
+
+        if vote:
+            self.log(f"投票给 {self.display(vote)}")
+            self.send_message({"vote": vote})
+
+    def handle_hunter_shoot(self, message):
+        """处理猎人开枪，可以选择不开枪"""
+        candidates = message.get("candidates", [])
+        self.log(f"猎人出局，可以开枪带走: {self.display(candidates)}")
+
+        if self.action_callback:
+            target = self.action_callback("hunter_shoot", candidates)
+        else:
+            target = random.choice(candidates) if candidates else None
+
+        if target:
+            self.log(f"开枪带走 {self.display(target)}")
+            self.send_message({"target": target})
+        else:
+            self.log("放弃开枪")
+            self.send_message({"skip": True})
+
+    def handle_wolf_king_target(self, message):
+        """处理白狼王自爆后选择带走的目标"""
+        candidates = message.get("candidates", [])
+        self.log(f"白狼王自爆，请选择带走的目标: {self.display(candidates)}")
+
+        if self.action_callback:
+            target = self.action_callback("wolf_king_target", candidates)
+        else:
+            target = random.choice(candidates) if candidates else None
+
+        if target:
+            self.log(f"带走 {self.display(target)}")
+            self.send_message({"target": target})
+
+    def handle_speech_direction(self, message):
+        """处理警长选择发言方向，或者服务器公布的发言方向"""
+        if "options" not in message:
+            self.log(f"警长 {self.display(message.get('sheriff'))} 选择从 {message.get('direction')} 方向开始发言")
+            return
+
+        options = message.get("options", [])
+        if self.action_callback:
+            direction = self.action_callback("speech_direction", options)
+        else:
+            direction = random.choice(options) if options else None
+
+        if direction:
+            self.log(f"选择从 {direction} 方向开始发言")
+            self.send_message({"direction": direction})
+
+    def handle_speech_turn(self, message):
+        """处理发言回合，轮到自己时发言并结束发言"""
+        speaker = message.get("player")
+        if speaker != self.player_id:
+            self.log(f"轮到 {self.display(speaker)} 发言")
+            return
+
+        self.log(f"轮到你发言，限时 {message.get('seconds')} 秒")
+        self.speak("speech")
+
+    def speak(self, action_type):
+        """发送一段发言并结束发言"""
+        if self.action_callback:
+            text = self.action_callback(action_type, [])
+        else:
+            text = random.choice(["我是好人，过。", "我先听听后面的发言。", "这一轮我没有特别的信息。"])
+
+        if action_type == "speech" and text and text.split()[0] == "/explode":
+            parts = text.split()
+            self.explode(parts[1] if len(parts) > 1 else None)
+        elif text:
+            self.send_message({"type": "speech", "text": text})
+        self.send_message({"type": "end_speech"})
+
+    def explode(self, target=None):
+        """白天自爆，白狼王可以同时带走一名玩家"""
+        message = {"type": "explode"}
+        if target:
+            message["target"] = target
+            self.log(f"选择自爆并带走 {self.display(target)}")
+        else:
+            self.log("选择自爆")
+        self.send_message(message)
+
+    def handle_speech(self, message):
+        """处理其他玩家的发言"""
+        self.log(f"{self.display(message.get('player'))}: {message.get('text')}")
+
+    def handle_last_words(self, message):
+        """处理出局后的遗言"""
+        self.log(f"你已出局，请发表遗言，限时 {message.get('seconds')} 秒")
+        self.speak("last_words")
+
+    def handle_last_words_speech(self, message):
+        """处理出局玩家的遗言"""
+        self.log(f"{self.display(message.get('player'))} 的遗言: {message.get('text')}")
+
+    def handle_badge_transfer(self, message):
+        """处理警长出局后移交警徽，或者服务器公布的移交结果"""
+        if "candidates" not in message:
+            if message.get("torn"):
+                self.log(f"警长 {self.display(message.get('sheriff'))} 撕毁了警徽")
+            else:
+                self.log(f"警长 {self.display(message.get('sheriff'))} 将警徽移交给 {self.display(message.get('heir'))}")
+            return
+
+        candidates = message.get("candidates", [])
+        self.log(f"你是出局的警长，请移交警徽: {self.display(candidates)}")
+
+        if self.action_callback:
+            heir = self.action_callback("badge_transfer", candidates)
+        else:
+            heir = random.choice(candidates) if candidates and random.random() < 0.8 else None
+
+        if heir:
+            self.log(f"将警徽移交给 {self.display(heir)}")
+            self.send_message({"target": heir})
+        else:
+            self.log("撕毁警徽")
+            self.send_message({"tear": True})
+
     def handle_game_end(self, message):
         """处理游戏结束"""
-        winner = message.get("winner", "未知")
-        self.log(f"游戏结束，{winner}阵营胜利！")
+        winner = message.get("winner", "unknown")
+        self.log(f"游戏结束，{message.get('text') or self.catalog['factions'].get(winner, winner)}")
         self.running = False
 
     def log(self, message):
@@ -312,8 +659,8 @@ class WerewolfClient:
             status = f"{name}"
             if sheriff:
                 status += "(警长)"
-            if role != "未知":
-                status += f"[{role}]"
+            if role != "unknown":
+                status += f"[{self.role_name(role)}]"
 
             if alive:
                 alive_players.append(status)
@@ -333,7 +680,15 @@ def interactive_callback(action_type, options):
     """交互式决策回调函数"""
     print(f"\n=== 需要你的决策: {action_type} ===")
 
-    if action_type == "sheriff_election":
+    if action_type == "sheriff_signup":
+        print("是否上警? (y/n)")
+        return input("> ").strip().lower().startswith('y')
+
+    elif action_type == "sheriff_withdraw":
+        print("是否退水? (y/n)")
+        return input("> ").strip().lower().startswith('y')
+
+    elif action_type == "sheriff_election":
         print("请选择警长候选人:")
         return prompt_selection(options)
 
@@ -358,10 +713,56 @@ def interactive_callback(action_type, options):
         print("请选择你要查验的目标:")
         return prompt_selection(options)
 
+    elif action_type == "cupid":
+        print("请选择第一名情侣:")
+        first = prompt_selection(options)
+        print("请选择第二名情侣:")
+        second = prompt_selection([o for o in options if o != first])
+        return [first, second] if first and second else None
+
+    elif action_type == "guard":
+        print("请选择你要守护的目标:")
+        return prompt_selection(options)
+
     elif action_type == "day_vote":
         print("请选择你要投票出局的玩家:")
         return prompt_selection(options)
 
+    elif action_type == "pk_vote":
+        print("请选择你要在PK中投票出局的玩家:")
+        return prompt_selection(options)
+
+    elif action_type == "wolf_king_target":
+        print("请选择你要带走的目标:")
+        return prompt_selection(options)
+
+    elif action_type == "speech_direction":
+        print("请选择发言方向:")
+        return prompt_selection(options)
+
+    elif action_type == "speech":
+        print("狼人可以输入 /explode 自爆，白狼王可以输入 /explode <玩家ID> 同时带走一名玩家")
+        return input("请输入你的发言（直接回车跳过）> ").strip()
+
+    elif action_type == "last_words":
+        return input("请输入你的遗言（直接回车跳过）> ").strip()
+
+    elif action_type == "badge_transfer":
+        print("是否移交警徽? (y/n，选择 n 撕毁警徽)")
+        choice = input("> ").strip().lower()
+        if choice.startswith('y'):
+            print("请选择警徽继承人:")
+            return prompt_selection(options)
+        return None
+
+    elif action_type == "hunter_shoot":
+        print("是否开枪? (y/n)")
+        choice = input("> ").strip().lower()
+        if choice.startswith('y'):
+            print("请选择你要带走的目标:")
+            return prompt_selection(options)
+        return None
+
     # 默认情况随机选择
     return random.choice(options) if options else None
 
@@ -396,6 +797,9 @@ def main():
 
     # 玩家设置
     parser.add_argument("--name", default=None, help="玩家名称")
+    parser.add_argument("--locale", default="zh-CN", choices=["zh-CN", "en"], help="服务器消息的显示语言")
+    parser.add_argument("--spectate", action="store_true", help="以观战者身份加入")
+    parser.add_argument("--god-token", default=None, help="上帝视角令牌，创建游戏时返回")
 
     # 游戏模式
     mode_group = parser.add_mutually_exclusive_group()
@@ -433,7 +837,7 @@ def main():
             if response.status_code == 200:
                 data = response.json()
                 game_port = data.get("port")
-                print(f"创建了新游戏，端口: {game_port}")
+                print(f"创建了新游戏，端口: {game_port}，上帝视角令牌: {data.get('god_token')}")
             else:
                 print(f"创建游戏失败: {response.text}")
                 return
@@ -442,10 +846,13 @@ def main():
             print(f"使用默认端口 {game_port} 连接")
 
     # 创建客户端
-    client = WerewolfClient(host=args.server, port=game_port, player_name=player_name)
+    client = WerewolfClient(host=args.server, port=game_port, player_name=player_name, locale=args.locale,
+                            spectate=args.spectate, god_token=args.god_token)
 
     # 设置交互模式
-    if args.interactive:
+    if args.spectate:
+        print("观战模式已启用，不会收到任何行动请求")
+    elif args.interactive:
         client.set_action_callback(interactive_callback)
         print("交互模式已启用，你将需要手动做出所有决策")
     else:
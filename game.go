@@ -1,27 +1,49 @@
 package main
 
 import (
+	crand "crypto/rand"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"log"
 	"math/rand"
 	"net"
 	"net/http"
+	"sort"
 	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
 // 用于创建游戏的请求结构
 type CreateGameRequest struct {
-	RealPlayers int `json:"real_players"`
-	AIPlayers   int `json:"ai_players"`
+	RealPlayers   int    `json:"real_players"`
+	AIPlayers     int    `json:"ai_players"`
+	Board         string `json:"board,omitempty"`
+	WinCondition  string `json:"win_condition,omitempty"`
+	Seed          int64  `json:"seed,omitempty"` // 随机种子，为0时自动生成
+	SpeechSeconds int    `json:"speech_seconds,omitempty"`
+	WolfSeconds   int    `json:"wolf_seconds,omitempty"`  // 狼人夜间商量刀口的时间（秒）
+	LastWords     string `json:"last_words,omitempty"`    // 夜间死亡玩家的遗言规则
+	BadgeDefault  string `json:"badge_default,omitempty"` // 警长移交警徽超时的默认处理
+
+	AIStrategy   string   `json:"ai_strategy,omitempty"`   // AI玩家默认使用的策略
+	AIStrategies []string `json:"ai_strategies,omitempty"` // 按加入顺序为每个AI座位单独指定策略，为空的项使用默认策略
+
+	WitchSelfSave    string `json:"witch_self_save,omitempty"`    // 女巫自救规则
+	WitchBothPotions bool   `json:"witch_both_potions,omitempty"` // 女巫同一晚可以同时使用两瓶药
+	WitchSeeVictim   bool   `json:"witch_see_victim,omitempty"`   // 女巫用完解药后仍能看到刀口
+
+	GodToken string `json:"god_token,omitempty"` // 观战者获得上帝视角的口令，为空时由服务器随机生成
 }
 
 // 创建游戏的响应结构
 type CreateGameResponse struct {
-	GameID int `json:"game_id"`
-	Port   int `json:"port"`
+	GameID   int    `json:"game_id"`
+	Port     int    `json:"port"`
+	GodToken string `json:"god_token"` // 本局实际使用的上帝视角口令，只返回给创建者
 }
 
 // 游戏状态响应结构
@@ -32,39 +54,262 @@ type GameStatusResponse struct {
 	Error   string      `json:"error,omitempty"`
 }
 
+// ============ 文案定义 ============
+
+// 默认语言，服务器日志和未指定语言的玩家使用
+const DefaultLocale = "zh-CN"
+
+// 各语言的文案，角色、阵营和查验结果以 role.、faction.、check. 加ID为键
+var catalogs = map[string]map[string]string{
+	"zh-CN": {
+		"role.werewolf":        "狼人",
+		"role.white_wolf_king": "白狼王",
+		"role.villager":        "平民",
+		"role.witch":           "女巫",
+		"role.seer":            "预言家",
+		"role.hunter":          "猎人",
+		"role.guard":           "守卫",
+		"role.idiot":           "白痴",
+		"role.cupid":           "丘比特",
+		"role.unknown":         "未知",
+
+		"faction.good":   "好人",
+		"faction.wolf":   "狼人",
+		"faction.lovers": "情侣",
+
+		"check.good": "好人",
+		"check.wolf": "狼人",
+
+		"list.separator": "，",
+		"game_end":       "%s阵营胜利！",
+		"seer_result":    "%s 的身份是 %s",
+
+		"reject.empty":          "回复为空",
+		"reject.not_bool":       "%s 必须是布尔值",
+		"reject.not_string":     "%s 必须是字符串",
+		"reject.invalid_choice": "%s 不是有效的选择",
+		"reject.two_lovers":     "targets 必须包含两名玩家",
+		"reject.invalid_lovers": "targets 中包含无效的玩家",
+		"reject.same_lover":     "两名情侣必须是不同的玩家",
+		"reject.no_antidote":    "今晚不能使用解药",
+		"reject.no_poison":      "毒药已经用完",
+		"reject.one_potion":     "每晚只能使用一瓶药",
+		"reject.game_started":   "游戏已经开始，只能以观战者身份加入",
+		"reject.cannot_explode": "只有存活的狼人可以自爆",
+		"reject.explode_closed": "现在不能自爆",
+
+		"ai.speech.0":         "我是好人，昨晚没有拿到信息，%s 的位置我会重点听一下。",
+		"ai.speech.1":         "我觉得 %s 的状态有点奇怪，今天可以考虑投这个位置。",
+		"ai.speech.2":         "我这边没有身份可以报，%s 如果有信息可以先站出来。",
+		"ai.speech.3":         "场上信息太少，先听听 %s 怎么说，我跟警长的票走。",
+		"ai.everyone":         "大家",
+		"ai.follow_seer":      "我相信预言家的查验，%s 是狼人，今天请大家一起投他的位置。",
+		"ai.seer_no_info":     "我是预言家，还没有查验信息，大家先听发言。",
+		"ai.seer_claim":       "我是预言家，%s。%s",
+		"ai.check_item":       "%s 是%s",
+		"ai.seer_push":        "今天请大家跟我投 %s。",
+		"ai.seer_no_wolf":     "目前没有查到狼人。",
+		"ai.last_words.wolf":  "我是好人，被冤枉出局了，大家擦亮眼睛找狼。",
+		"ai.last_words.god":   "我是%s，身份已经交代清楚，剩下的就靠大家了。",
+		"ai.last_words.plain": "我是平民，没有更多信息，好人加油。",
+	},
+	"en": {
+		"role.werewolf":        "Werewolf",
+		"role.white_wolf_king": "White Wolf King",
+		"role.villager":        "Villager",
+		"role.witch":           "Witch",
+		"role.seer":            "Seer",
+		"role.hunter":          "Hunter",
+		"role.guard":           "Guard",
+		"role.idiot":           "Idiot",
+		"role.cupid":           "Cupid",
+		"role.unknown":         "Unknown",
+
+		"faction.good":   "Villagers",
+		"faction.wolf":   "Werewolves",
+		"faction.lovers": "Lovers",
+
+		"check.good": "good",
+		"check.wolf": "a werewolf",
+
+		"list.separator": ", ",
+		"game_end":       "%s win!",
+		"seer_result":    "%s is %s",
+
+		"reject.empty":          "empty response",
+		"reject.not_bool":       "%s must be a boolean",
+		"reject.not_string":     "%s must be a string",
+		"reject.invalid_choice": "%s is not a valid choice",
+		"reject.two_lovers":     "targets must name two players",
+		"reject.invalid_lovers": "targets contains an invalid player",
+		"reject.same_lover":     "the two lovers must be different players",
+		"reject.no_antidote":    "the antidote cannot be used tonight",
+		"reject.no_poison":      "the poison has already been used",
+		"reject.one_potion":     "only one potion may be used per night",
+		"reject.game_started":   "the game has already started, you can only join as a spectator",
+		"reject.cannot_explode": "only a living werewolf can self-destruct",
+		"reject.explode_closed": "you cannot self-destruct right now",
+
+		"ai.speech.0":         "I'm good and got no information last night. I'll be listening closely to %s.",
+		"ai.speech.1":         "%s seems a bit off to me. We could consider voting there today.",
+		"ai.speech.2":         "I have nothing to claim. %s, if you have information, please step forward.",
+		"ai.speech.3":         "There's too little information. Let's hear what %s says; I'll follow the sheriff's vote.",
+		"ai.everyone":         "everyone",
+		"ai.follow_seer":      "I trust the seer's check: %s is a werewolf. Let's all vote there today.",
+		"ai.seer_no_info":     "I'm the seer, but I have no checks yet. Let's hear everyone out first.",
+		"ai.seer_claim":       "I'm the seer: %s. %s",
+		"ai.check_item":       "%s is %s",
+		"ai.seer_push":        "Please vote %s with me today.",
+		"ai.seer_no_wolf":     "I haven't found a werewolf yet.",
+		"ai.last_words.wolf":  "I'm good and was framed. Keep your eyes open for the wolves.",
+		"ai.last_words.god":   "I'm the %s and I've told you all I know. The rest is up to you.",
+		"ai.last_words.plain": "I'm a villager with no more information. Good luck, everyone.",
+	},
+}
+
+// AI发言模板的数量，对应 ai.speech.N
+const aiSpeechLines = 4
+
+// 校验语言，为空或不支持时返回默认语言
+func ParseLocale(locale string) string {
+	if _, ok := catalogs[locale]; ok {
+		return locale
+	}
+	return DefaultLocale
+}
+
+// 需要按玩家语言显示的文本，发送前按接收者的语言渲染
+type LocalText struct {
+	Key  string        // 文案键，为空时原样显示第一个参数
+	Args []interface{} // 格式化参数，可以是 LocalText 或 []LocalText
+}
+
+// 创建按文案键翻译的文本
+func Text(key string, args ...interface{}) LocalText {
+	return LocalText{Key: key, Args: args}
+}
+
+// 创建不需要翻译的文本，如玩家自己的发言
+func Raw(text string) LocalText {
+	return LocalText{Args: []interface{}{text}}
+}
+
+// 按指定语言渲染文本，缺少的文案回退到默认语言
+func (t LocalText) Render(locale string) string {
+	args := make([]interface{}, len(t.Args))
+	for i, arg := range t.Args {
+		switch a := arg.(type) {
+		case LocalText:
+			args[i] = a.Render(locale)
+		case []LocalText:
+			parts := []string{}
+			for _, part := range a {
+				parts = append(parts, part.Render(locale))
+			}
+			args[i] = strings.Join(parts, Text("list.separator").Render(locale))
+		default:
+			args[i] = a
+		}
+	}
+
+	if t.Key == "" {
+		if len(args) == 0 {
+			return ""
+		}
+		return fmt.Sprint(args[0])
+	}
+	format, ok := catalogs[ParseLocale(locale)][t.Key]
+	if !ok {
+		format, ok = catalogs[DefaultLocale][t.Key]
+	}
+	if !ok {
+		return t.Key
+	}
+	if len(args) == 0 {
+		return format
+	}
+	return fmt.Sprintf(format, args...)
+}
+
+// 角色的显示名称
+func RoleName(locale, roleID string) string {
+	return Text("role." + roleID).Render(locale)
+}
+
+// 把消息中的 LocalText 渲染为接收者语言的字符串
+func localize(message map[string]interface{}, locale string) map[string]interface{} {
+	localized := map[string]interface{}{}
+	for k, v := range message {
+		if text, ok := v.(LocalText); ok {
+			localized[k] = text.Render(locale)
+		} else {
+			localized[k] = v
+		}
+	}
+	return localized
+}
+
+// 某种语言下所有角色、阵营和查验结果的显示名称，连接时发送给客户端
+func catalogFor(locale string) map[string]interface{} {
+	locale = ParseLocale(locale)
+	sections := map[string]interface{}{"type": "catalog", "locale": locale}
+	for _, section := range []string{"role", "faction", "check"} {
+		names := map[string]string{}
+		for key, text := range catalogs[locale] {
+			if strings.HasPrefix(key, section+".") {
+				names[strings.TrimPrefix(key, section+".")] = text
+			}
+		}
+		sections[section+"s"] = names
+	}
+	return sections
+}
+
 // ============ 角色定义 ============
 
+// 角色ID，协议和游戏结果中使用，与显示语言无关
+const (
+	RoleWerewolf      = "werewolf"
+	RoleWhiteWolfKing = "white_wolf_king"
+	RoleVillager      = "villager"
+	RoleWitch         = "witch"
+	RoleSeer          = "seer"
+	RoleHunter        = "hunter"
+	RoleGuard         = "guard"
+	RoleIdiot         = "idiot"
+	RoleCupid         = "cupid"
+	RoleUnknown       = "unknown" // 不知道身份的玩家
+)
+
 // 角色接口
 type Role interface {
+	GetID() string
 	GetName() string
 	NightAction(player *Player, allPlayers []*Player) map[string]interface{}
 	DayAction(player *Player, allPlayers []*Player) map[string]interface{}
 }
 
 // 狼人角色
-type Wolf struct {
-	Name string
-}
+type Wolf struct{}
 
 func NewWolf() *Wolf {
-	return &Wolf{Name: "狼人"}
+	return &Wolf{}
+}
+
+func (w *Wolf) GetID() string {
+	return RoleWerewolf
 }
 
 func (w *Wolf) GetName() string {
-	return w.Name
+	return RoleName(DefaultLocale, w.GetID())
 }
 
+// AI狼人按策略投票击杀一名存活的非狼人玩家
 func (w *Wolf) NightAction(player *Player, allPlayers []*Player) map[string]interface{} {
 	if player.IsAI {
-		validTargets := []*Player{}
-		for _, p := range allPlayers {
-			if p.Alive && !p.IsWolf() {
-				validTargets = append(validTargets, p)
-			}
-		}
-		if len(validTargets) > 0 {
-			target := validTargets[rand.Intn(len(validTargets))]
-			return map[string]interface{}{"vote": target.Name}
+		target := aliveTarget(allPlayers, player.AI().WolfKill(player.View()))
+		if target != nil && !target.IsWolf() {
+			return map[string]interface{}{"target": target.ID}
 		}
 	}
 	return nil
@@ -74,17 +319,49 @@ func (w *Wolf) DayAction(player *Player, allPlayers []*Player) map[string]interf
 	return nil
 }
 
-// 平民角色
-type Villager struct {
-	Name string
+// 白狼王角色，夜晚与狼人一同行动，白天自爆时可带走一名玩家
+type WhiteWolfKing struct {
+	Wolf
+}
+
+func NewWhiteWolfKing() *WhiteWolfKing {
+	return &WhiteWolfKing{}
+}
+
+func (k *WhiteWolfKing) GetID() string {
+	return RoleWhiteWolfKing
+}
+
+func (k *WhiteWolfKing) GetName() string {
+	return RoleName(DefaultLocale, k.GetID())
+}
+
+// AI白狼王自爆时按策略选择带走的玩家
+func (k *WhiteWolfKing) DayAction(player *Player, allPlayers []*Player) map[string]interface{} {
+	if !player.IsAI {
+		return nil
+	}
+
+	candidates := otherAliveIDs(player, allPlayers)
+	if target := aliveTarget(allPlayers, player.AI().WolfKingTarget(player.View(), candidates)); target != nil && target != player {
+		return map[string]interface{}{"target": target.ID}
+	}
+	return nil
 }
 
+// 平民角色
+type Villager struct{}
+
 func NewVillager() *Villager {
-	return &Villager{Name: "平民"}
+	return &Villager{}
+}
+
+func (v *Villager) GetID() string {
+	return RoleVillager
 }
 
 func (v *Villager) GetName() string {
-	return v.Name
+	return RoleName(DefaultLocale, v.GetID())
 }
 
 func (v *Villager) NightAction(player *Player, allPlayers []*Player) map[string]interface{} {
@@ -97,64 +374,53 @@ func (v *Villager) DayAction(player *Player, allPlayers []*Player) map[string]in
 
 // 女巫角色
 type Witch struct {
-	Name           string
-	HasPoison      bool
-	HasAntidote    bool
-	Game           *WerewolfGame
-	PoisonedTarget string
+	HasPoison   bool
+	HasAntidote bool
+	Game        *WerewolfGame
 }
 
 func NewWitch() *Witch {
 	return &Witch{
-		Name:           "女巫",
-		HasPoison:      true,
-		HasAntidote:    true,
-		PoisonedTarget: "",
+		HasPoison:   true,
+		HasAntidote: true,
 	}
 }
 
+func (w *Witch) GetID() string {
+	return RoleWitch
+}
+
 func (w *Witch) GetName() string {
-	return w.Name
+	return RoleName(DefaultLocale, w.GetID())
 }
 
+// AI女巫按策略决定用药，药剂在夜晚结算时才消耗
 func (w *Witch) NightAction(player *Player, allPlayers []*Player) map[string]interface{} {
 	if !player.IsAI {
 		return nil
 	}
 
-	// AI女巫使用解药
-	if player.IsAI && w.HasAntidote && w.Game != nil && w.Game.WolfKillTarget != "" {
-		// 只对当晚狼人杀死的人使用解药
-		if rand.Float64() < 0.7 {
-			w.HasAntidote = false
-			w.Game.Antidote = true
-			return map[string]interface{}{
-				"action": "save",
-				"target": w.Game.WolfKillTarget,
-			}
+	result := map[string]interface{}{}
+	if w.Game != nil {
+		w.Game.ShowWitchVictim(player)
+	}
+	save, poisonID := player.AI().Witch(player.View())
+
+	// AI女巫使用解药，只对当晚狼人杀死的人使用
+	if save && w.Game != nil && w.Game.CanWitchSave(player) {
+		result["save"] = w.Game.Night.Target(ActionKill)
+		if !w.Game.Options.Witch.BothPotions {
+			return result
 		}
 	}
 
 	// AI女巫使用毒药
-	if player.IsAI && w.HasPoison && w.PoisonedTarget == "" {
-		validTargets := []*Player{}
-		for _, p := range allPlayers {
-			if p.Alive && p.IsWolf() {
-				validTargets = append(validTargets, p)
-			}
-		}
-		if len(validTargets) > 0 && rand.Float64() < 0.3 {
-			target := validTargets[rand.Intn(len(validTargets))]
-			w.HasPoison = false
-			w.PoisonedTarget = target.Name
-			return map[string]interface{}{
-				"action": "poison",
-				"target": target.Name,
-			}
+	if w.HasPoison {
+		if target := aliveTarget(allPlayers, poisonID); target != nil && target != player {
+			result["poison"] = target.ID
 		}
 	}
-
-	return nil
+	return result
 }
 
 func (w *Witch) DayAction(player *Player, allPlayers []*Player) map[string]interface{} {
@@ -162,40 +428,30 @@ func (w *Witch) DayAction(player *Player, allPlayers []*Player) map[string]inter
 }
 
 // 预言家角色
-type Seer struct {
-	Name string
-}
+type Seer struct{}
 
 func NewSeer() *Seer {
-	return &Seer{Name: "预言家"}
+	return &Seer{}
+}
+
+func (s *Seer) GetID() string {
+	return RoleSeer
 }
 
 func (s *Seer) GetName() string {
-	return s.Name
+	return RoleName(DefaultLocale, s.GetID())
 }
 
+// AI预言家按策略查验一名存活的其他玩家
 func (s *Seer) NightAction(player *Player, allPlayers []*Player) map[string]interface{} {
 	if !player.IsAI {
 		return nil
 	}
 
-	validTargets := []*Player{}
-	for _, p := range allPlayers {
-		if p.Alive && p != player {
-			validTargets = append(validTargets, p)
-		}
-	}
-
-	if len(validTargets) > 0 {
-		target := validTargets[rand.Intn(len(validTargets))]
-		result := "狼人"
-		if !target.IsWolf() {
-			result = "好人"
-		}
+	if target := aliveTarget(allPlayers, player.AI().SeerCheck(player.View())); target != nil && target != player {
 		return map[string]interface{}{
 			"action": "check",
-			"target": target.Name,
-			"result": result,
+			"target": target.ID,
 		}
 	}
 	return nil
@@ -206,90 +462,389 @@ func (s *Seer) DayAction(player *Player, allPlayers []*Player) map[string]interf
 }
 
 // 猎人角色
-type Hunter struct {
-	Name string
-}
+type Hunter struct{}
 
 func NewHunter() *Hunter {
-	return &Hunter{Name: "猎人"}
+	return &Hunter{}
+}
+
+func (h *Hunter) GetID() string {
+	return RoleHunter
 }
 
 func (h *Hunter) GetName() string {
-	return h.Name
+	return RoleName(DefaultLocale, h.GetID())
 }
 
 func (h *Hunter) NightAction(player *Player, allPlayers []*Player) map[string]interface{} {
 	return nil
 }
 
+// 猎人死亡后选择开枪目标，被毒死或殉情时不能开枪，击杀由游戏结算
 func (h *Hunter) DayAction(player *Player, allPlayers []*Player) map[string]interface{} {
-	if !player.Alive {
+	if !player.Alive && !player.Poisoned && player.DeathCause != DeathByLove {
 		if !player.IsAI {
 			return nil
 		}
 
-		validTargets := []*Player{}
-		for _, p := range allPlayers {
-			if p.Alive && p != player {
-				validTargets = append(validTargets, p)
+		candidates := otherAliveIDs(player, allPlayers)
+		if target := aliveTarget(allPlayers, player.AI().HunterShoot(player.View(), candidates)); target != nil && target != player {
+			return map[string]interface{}{"target": target.ID}
+		}
+	}
+	return nil
+}
+
+// 守卫角色
+type Guard struct {
+	LastProtected string // 上一晚守护的玩家ID
+}
+
+func NewGuard() *Guard {
+	return &Guard{}
+}
+
+func (g *Guard) GetID() string {
+	return RoleGuard
+}
+
+func (g *Guard) GetName() string {
+	return RoleName(DefaultLocale, g.GetID())
+}
+
+// AI守卫按策略守护一名存活玩家，不能连续两晚守护同一人，选择无效时空守
+func (g *Guard) NightAction(player *Player, allPlayers []*Player) map[string]interface{} {
+	if !player.IsAI {
+		return nil
+	}
+
+	targetID := ""
+	if target := aliveTarget(allPlayers, player.AI().Guard(player.View())); target != nil && target.ID != g.LastProtected {
+		targetID = target.ID
+	}
+	return map[string]interface{}{
+		"action": "guard",
+		"target": targetID,
+	}
+}
+
+func (g *Guard) DayAction(player *Player, allPlayers []*Player) map[string]interface{} {
+	return nil
+}
+
+// 白痴角色，被放逐时翻牌免于出局，但失去投票权
+type Idiot struct{}
+
+func NewIdiot() *Idiot {
+	return &Idiot{}
+}
+
+func (i *Idiot) GetID() string {
+	return RoleIdiot
+}
+
+func (i *Idiot) GetName() string {
+	return RoleName(DefaultLocale, i.GetID())
+}
+
+func (i *Idiot) NightAction(player *Player, allPlayers []*Player) map[string]interface{} {
+	return nil
+}
+
+func (i *Idiot) DayAction(player *Player, allPlayers []*Player) map[string]interface{} {
+	return nil
+}
+
+// 丘比特角色，第一晚连接两名玩家成为情侣
+type Cupid struct {
+	Linked bool
+}
+
+func NewCupid() *Cupid {
+	return &Cupid{}
+}
+
+func (c *Cupid) GetID() string {
+	return RoleCupid
+}
+
+func (c *Cupid) GetName() string {
+	return RoleName(DefaultLocale, c.GetID())
+}
+
+// AI丘比特按策略连接两名存活玩家
+func (c *Cupid) NightAction(player *Player, allPlayers []*Player) map[string]interface{} {
+	if !player.IsAI || c.Linked {
+		return nil
+	}
+
+	targets := player.AI().CupidLink(player.View())
+	if len(targets) == 2 && targets[0] != targets[1] {
+		first, second := aliveTarget(allPlayers, targets[0]), aliveTarget(allPlayers, targets[1])
+		if first != nil && second != nil {
+			return map[string]interface{}{
+				"action":  "link",
+				"targets": []string{first.ID, second.ID},
 			}
 		}
-		if len(validTargets) > 0 {
-			target := validTargets[rand.Intn(len(validTargets))]
-			target.Alive = false
-			return map[string]interface{}{"target": target.Name}
+	}
+	return nil
+}
+
+func (c *Cupid) DayAction(player *Player, allPlayers []*Player) map[string]interface{} {
+	return nil
+}
+
+// 查找AI选择的存活玩家，目标无效时返回nil
+func aliveTarget(allPlayers []*Player, id string) *Player {
+	for _, p := range allPlayers {
+		if p.ID == id && p.Alive {
+			return p
 		}
 	}
 	return nil
 }
 
-// 创建角色函数
-func CreateRole(roleName string) Role {
-	switch roleName {
-	case "狼人":
+// 除自己外的存活玩家ID，作为AI决策的候选
+func otherAliveIDs(player *Player, allPlayers []*Player) []string {
+	ids := []string{}
+	for _, p := range allPlayers {
+		if p.Alive && p != player {
+			ids = append(ids, p.ID)
+		}
+	}
+	return ids
+}
+
+// 按角色ID创建角色
+func CreateRole(roleID string) Role {
+	switch roleID {
+	case RoleWerewolf:
 		return NewWolf()
-	case "平民":
+	case RoleVillager:
 		return NewVillager()
-	case "女巫":
+	case RoleWitch:
 		return NewWitch()
-	case "预言家":
+	case RoleSeer:
 		return NewSeer()
-	case "猎人":
+	case RoleHunter:
 		return NewHunter()
+	case RoleGuard:
+		return NewGuard()
+	case RoleIdiot:
+		return NewIdiot()
+	case RoleWhiteWolfKing:
+		return NewWhiteWolfKing()
+	case RoleCupid:
+		return NewCupid()
 	default:
 		return NewVillager()
 	}
 }
 
+// ============ 板子定义 ============
+
+// 默认板子，沿用按人数动态生成的配置
+const DefaultBoard = "classic"
+
+// 板子（角色配置）
+type RoleBoard struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	PlayerCount int      `json:"player_count"`    // 0 表示不固定人数
+	Roles       []string `json:"roles,omitempty"` // 角色ID
+}
+
+// 可选板子
+var roleBoards = map[string]*RoleBoard{
+	DefaultBoard: {
+		Name:        DefaultBoard,
+		Description: "经典局: 1狼(5人及以上2狼) 预言家 女巫 其余平民",
+		PlayerCount: 0,
+	},
+	"standard6": {
+		Name:        "standard6",
+		Description: "6人预女局: 2狼 预言家 女巫 2平民",
+		PlayerCount: 6,
+		Roles:       []string{RoleWerewolf, RoleWerewolf, RoleSeer, RoleWitch, RoleVillager, RoleVillager},
+	},
+	"standard9": {
+		Name:        "standard9",
+		Description: "9人预女猎: 3狼 预言家 女巫 猎人 3平民",
+		PlayerCount: 9,
+		Roles:       []string{RoleWerewolf, RoleWerewolf, RoleWerewolf, RoleSeer, RoleWitch, RoleHunter, RoleVillager, RoleVillager, RoleVillager},
+	},
+	"standard12": {
+		Name:        "standard12",
+		Description: "12人预女猎白: 4狼 预言家 女巫 猎人 白痴 4平民",
+		PlayerCount: 12,
+		Roles: []string{RoleWerewolf, RoleWerewolf, RoleWerewolf, RoleWerewolf, RoleSeer, RoleWitch, RoleHunter, RoleIdiot,
+			RoleVillager, RoleVillager, RoleVillager, RoleVillager},
+	},
+	"wolfking12": {
+		Name:        "wolfking12",
+		Description: "12人白狼王守卫: 3狼 白狼王 预言家 女巫 猎人 守卫 4平民",
+		PlayerCount: 12,
+		Roles: []string{RoleWerewolf, RoleWerewolf, RoleWerewolf, RoleWhiteWolfKing, RoleSeer, RoleWitch, RoleHunter, RoleGuard,
+			RoleVillager, RoleVillager, RoleVillager, RoleVillager},
+	},
+	"cupid12": {
+		Name:        "cupid12",
+		Description: "12人丘比特局: 4狼 预言家 女巫 猎人 丘比特 4平民，人狼恋成为第三方",
+		PlayerCount: 12,
+		Roles: []string{RoleWerewolf, RoleWerewolf, RoleWerewolf, RoleWerewolf, RoleSeer, RoleWitch, RoleHunter, RoleCupid,
+			RoleVillager, RoleVillager, RoleVillager, RoleVillager},
+	},
+	"guard12": {
+		Name:        "guard12",
+		Description: "12人预女猎守: 4狼 预言家 女巫 猎人 守卫 4平民",
+		PlayerCount: 12,
+		Roles: []string{RoleWerewolf, RoleWerewolf, RoleWerewolf, RoleWerewolf, RoleSeer, RoleWitch, RoleHunter, RoleGuard,
+			RoleVillager, RoleVillager, RoleVillager, RoleVillager},
+	},
+}
+
+// 根据名称获取板子，名称为空时使用默认板子
+func GetRoleBoard(name string) (*RoleBoard, error) {
+	if name == "" {
+		name = DefaultBoard
+	}
+	board, ok := roleBoards[name]
+	if !ok {
+		return nil, fmt.Errorf("未知板子: %s", name)
+	}
+	return board, nil
+}
+
+// 生成指定人数下的角色列表，人数不符时返回错误
+func (b *RoleBoard) RolesFor(numPlayers int) ([]string, error) {
+	if b.PlayerCount == 0 {
+		// 经典局: 1狼，5人及以上2狼，加预言家和女巫，其余平民
+		if numPlayers < 3 {
+			return nil, fmt.Errorf("板子 %s 至少需要 3 名玩家，当前 %d 名", b.Name, numPlayers)
+		}
+		werewolfCount := 1
+		if numPlayers >= 5 {
+			werewolfCount = 2
+		}
+		roles := []string{}
+		for i := 0; i < werewolfCount; i++ {
+			roles = append(roles, RoleWerewolf)
+		}
+		roles = append(roles, RoleSeer, RoleWitch)
+		for len(roles) < numPlayers {
+			roles = append(roles, RoleVillager)
+		}
+		return roles, nil
+	}
+
+	if numPlayers != b.PlayerCount {
+		return nil, fmt.Errorf("板子 %s 需要 %d 名玩家，当前 %d 名", b.Name, b.PlayerCount, numPlayers)
+	}
+	roles := make([]string, len(b.Roles))
+	copy(roles, b.Roles)
+	return roles, nil
+}
+
 // ============ 玩家定义 ============
 
+// 阵营ID，预言家的查验结果也使用 good 和 wolf
+const (
+	FactionGood   = "good"
+	FactionWolf   = "wolf"
+	FactionLovers = "lovers"
+)
+
+// 死亡原因
+const (
+	DeathByWolf     = "wolf"
+	DeathByPoison   = "poison"
+	DeathByVote     = "vote"
+	DeathByHunter   = "hunter"
+	DeathByExplode  = "explode"
+	DeathByWolfKing = "wolf_king"
+	DeathByLove     = "love"
+)
+
 // 玩家结构体
 type Player struct {
-	Name     string
-	Role     Role
-	IsAI     bool
-	Alive    bool
-	Votes    float64
-	Sheriff  bool
-	Poisoned bool
+	ID         string // 座位号，协议和游戏状态中唯一标识玩家
+	Name       string // 显示名称，加入时去重
+	Role       Role
+	IsAI       bool
+	Alive      bool
+	Votes      float64
+	Sheriff    bool
+	Poisoned   bool
+	Revealed   bool    // 身份已公开（如白痴翻牌）
+	CanVote    bool    // 是否拥有投票权
+	Locale     string  // 玩家使用的语言
+	IsBot      bool    // 由外部机器人程序控制
+	TakenOver  bool    // 外部机器人超时或断开后由内置AI接管
+	Lover      *Player // 丘比特连接的情侣
+	DeathCause string
+	Strategy   AIStrategy // AI玩家的决策策略
+	Knowledge  *Knowledge // 玩家掌握的信息
+	game       *WerewolfGame
 }
 
 // 创建新玩家
 func NewPlayer(name string, isAI bool) *Player {
 	return &Player{
-		Name:     name,
-		Role:     nil,
-		IsAI:     isAI,
-		Alive:    true,
-		Votes:    0,
-		Sheriff:  false,
-		Poisoned: false,
+		Name:      name,
+		Role:      nil,
+		IsAI:      isAI,
+		Alive:     true,
+		Votes:     0,
+		Sheriff:   false,
+		Poisoned:  false,
+		Revealed:  false,
+		CanVote:   true,
+		Locale:    DefaultLocale,
+		Knowledge: NewKnowledge(),
 	}
 }
 
-// 判断是否是狼人
+// 判断是否是狼人（包括白狼王）
 func (p *Player) IsWolf() bool {
-	_, ok := p.Role.(*Wolf)
+	switch p.Role.(type) {
+	case *Wolf, *WhiteWolfKing:
+		return true
+	}
+	return false
+}
+
+// 玩家所在游戏的随机数源，AI决策都通过它进行以便复现
+func (p *Player) Rand() *rand.Rand {
+	if p.game != nil {
+		return p.game.rng
+	}
+	return fallbackRand
+}
+
+// 不属于任何游戏的玩家使用的随机数源
+var fallbackRand = rand.New(rand.NewSource(time.Now().UnixNano()))
+
+// AI玩家的决策策略，未指定时使用默认策略
+func (p *Player) AI() AIStrategy {
+	if p.Strategy == nil {
+		p.Strategy = NewAIStrategy(DefaultStrategy)
+	}
+	return p.Strategy
+}
+
+// 判断是否是神职（除狼人和平民外的特殊角色）
+func (p *Player) IsGod() bool {
+	if p.IsWolf() {
+		return false
+	}
+	_, isVillager := p.Role.(*Villager)
+	return !isVillager
+}
+
+// 判断是否是白狼王
+func (p *Player) IsWhiteWolfKing() bool {
+	_, ok := p.Role.(*WhiteWolfKing)
 	return ok
 }
 
@@ -311,6 +866,24 @@ func (p *Player) IsHunter() bool {
 	return ok
 }
 
+// 判断是否是守卫
+func (p *Player) IsGuard() bool {
+	_, ok := p.Role.(*Guard)
+	return ok
+}
+
+// 判断是否是丘比特
+func (p *Player) IsCupid() bool {
+	_, ok := p.Role.(*Cupid)
+	return ok
+}
+
+// 判断是否是白痴
+func (p *Player) IsIdiot() bool {
+	_, ok := p.Role.(*Idiot)
+	return ok
+}
+
 // 夜间行动
 func (p *Player) NightAction(allPlayers []*Player) map[string]interface{} {
 	if p.Role != nil {
@@ -327,1076 +900,4485 @@ func (p *Player) DayAction(allPlayers []*Player) map[string]interface{} {
 	return nil
 }
 
-// ============ 游戏事件定义 ============
-
-// 游戏事件接口
-type GameEvent interface {
-	GetName() string
-	GetDescription() string
-	Execute(game *WerewolfGame)
+// ============ AI策略定义 ============
+
+// AI策略，每个AI玩家可以使用不同的策略，决策时只能看到该玩家自己的视角
+type AIStrategy interface {
+	Name() string
+	WolfKill(v *AIView) string                                      // 狼人夜间投票击杀的目标
+	Guard(v *AIView) string                                         // 守卫守护的目标，空字符串表示空守
+	Witch(v *AIView) (save bool, poison string)                     // 女巫是否救刀口、毒杀的目标
+	SeerCheck(v *AIView) string                                     // 预言家查验的目标
+	CupidLink(v *AIView) []string                                   // 丘比特连接的两名玩家
+	RunForSheriff(v *AIView) bool                                   // 是否上警
+	WithdrawSheriff(v *AIView) bool                                 // 发言后是否退水
+	SheriffVote(v *AIView, candidates []string) string              // 警长投票
+	DayVote(v *AIView, candidates []string) string                  // 放逐投票，空字符串表示弃票
+	Speech(v *AIView) (text LocalText, claims map[string]string)    // 白天发言及公开的查验结果
+	LastWords(v *AIView) (text LocalText, claims map[string]string) // 遗言及公开的查验结果
+	SpeechDirection(v *AIView) string                               // 警长选择的发言方向
+	HunterShoot(v *AIView, candidates []string) string              // 猎人开枪的目标，空字符串表示不开枪
+	BadgeHeir(v *AIView, candidates []string) string                // 警徽继承人，空字符串表示撕毁警徽
+	Explode(v *AIView) bool                                         // 即将被放逐时是否自爆
+	WolfKingTarget(v *AIView, candidates []string) string           // 白狼王自爆带走的目标
 }
 
-// 白天事件
-type DayEvent struct {
-	Name        string
-	Description string
-}
+// AI策略名称
+const (
+	StrategyHeuristic = "heuristic" // 根据已知信息决策
+	StrategyRandom    = "random"    // 完全随机决策
+)
 
-func NewDayEvent(name, description string) *DayEvent {
-	return &DayEvent{
-		Name:        name,
-		Description: description,
-	}
-}
+// 默认AI策略
+const DefaultStrategy = StrategyHeuristic
 
-func (d *DayEvent) GetName() string {
-	return d.Name
+// 已注册的AI策略
+var aiStrategies = map[string]func() AIStrategy{
+	StrategyHeuristic: func() AIStrategy { return &HeuristicStrategy{} },
+	StrategyRandom:    func() AIStrategy { return &RandomStrategy{} },
 }
 
-func (d *DayEvent) GetDescription() string {
-	return d.Description
+// 校验AI策略名称，为空时返回默认的 heuristic
+func ParseAIStrategy(name string) (string, error) {
+	if name == "" {
+		return DefaultStrategy, nil
+	}
+	if _, ok := aiStrategies[name]; !ok {
+		return "", fmt.Errorf("未知AI策略: %s (可选 heuristic, random)", name)
+	}
+	return name, nil
 }
 
-func (d *DayEvent) Execute(game *WerewolfGame) {
-	if game.Sheriff == nil {
-		fmt.Println("警长选举", "玩家投票选举警长")
-		game.ElectSheriff()
+// 按名称创建AI策略，未知名称使用默认策略
+func NewAIStrategy(name string) AIStrategy {
+	factory, ok := aiStrategies[name]
+	if !ok {
+		factory = aiStrategies[DefaultStrategy]
 	}
-	fmt.Printf("\n=== %s ===\n", d.Name)
-	game.DayActions()
-	game.Vote()
+	return factory()
 }
 
-// 夜晚事件
-type NightEvent struct {
-	Name        string
-	Description string
+// 玩家视角中的一个座位
+type SeatView struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	Role     string `json:"role,omitempty"` // 该玩家知道的角色，不知道时为空
+	Alive    bool   `json:"alive"`
+	Sheriff  bool   `json:"sheriff"`
+	Revealed bool   `json:"revealed"`
 }
 
-func NewNightEvent(name, description string) *NightEvent {
-	return &NightEvent{
-		Name:        name,
-		Description: description,
-	}
+// AI决策时看到的局面，只包含所控制玩家自己可见的信息，也随提示以 ai_view 字段发送给外部机器人
+type AIView struct {
+	Self          SeatView    `json:"self"`
+	Day           int         `json:"day"`
+	Lover         string      `json:"lover,omitempty"` // 自己的情侣
+	Knowledge     *Knowledge  `json:"knowledge"`       // 自己掌握的信息
+	Seats         []SeatView  `json:"seats"`
+	Events        []GameEvent `json:"events"`                   // 该玩家可见的事件
+	Victim        string      `json:"victim,omitempty"`         // 女巫看到的当晚刀口
+	CanSave       bool        `json:"can_save"`                 // 女巫今晚能否使用解药
+	CanPoison     bool        `json:"can_poison"`               // 女巫是否还有毒药
+	LastProtected string      `json:"last_protected,omitempty"` // 守卫上一晚守护的玩家
+	rng           *rand.Rand
 }
 
-func (n *NightEvent) GetName() string {
-	return n.Name
+// 判断角色ID是否属于狼人阵营
+func isWolfRole(role string) bool {
+	return role == RoleWerewolf || role == RoleWhiteWolfKing
 }
 
-func (n *NightEvent) GetDescription() string {
-	return n.Description
-}
+// 生成玩家视角的局面
+func (p *Player) View() *AIView {
+	g := p.game
+	if g == nil {
+		return &AIView{Self: SeatView{ID: p.ID, Name: p.Name, Role: p.Role.GetID(), Alive: p.Alive}, Knowledge: p.Knowledge.Snapshot(), rng: fallbackRand}
+	}
 
-func (n *NightEvent) Execute(game *WerewolfGame) {
-	fmt.Printf("\n=== %s ===\n", n.Name)
-	game.NightActions()
-}
+	k := p.Knowledge.Snapshot()
+	v := &AIView{
+		Day:       g.DayCount,
+		Lover:     k.Lover,
+		Knowledge: k,
+		Events:    g.EventsFor(p),
+		rng:       g.rng,
+	}
+	for _, other := range g.Players {
+		seat := SeatView{
+			ID:       other.ID,
+			Name:     other.Name,
+			Alive:    other.Alive,
+			Sheriff:  other.Sheriff,
+			Revealed: other.Revealed,
+		}
+		seat.Role = k.Roles[other.ID]
+		if other == p {
+			v.Self = seat
+		}
+		v.Seats = append(v.Seats, seat)
+	}
 
-// ============ 游戏核心定义 ============
+	switch role := p.Role.(type) {
+	case *Witch:
+		v.Victim = k.Victims[g.DayCount]
+		v.CanSave = g.CanWitchSave(p)
+		v.CanPoison = k.Potions != nil && k.Potions.Poison
+	case *Guard:
+		v.LastProtected = role.LastProtected
+	}
+	return v
+}
 
-// 狼人杀游戏
-type WerewolfGame struct {
-	Players          []*Player
-	Events           []GameEvent
-	DayCount         int
-	Sheriff          *Player
-	SheriffElect     bool
-	WolfKillTarget   string
-	Antidote         bool
-	HumanWolfVotes   map[string]int
-	PoisonedPlayers  []string
-	WinnerIsWerewolf bool
-	Logs             []string
-	mu               sync.Mutex
+// 随机选择一个ID，列表为空时返回空字符串
+func (v *AIView) pick(ids []string) string {
+	if len(ids) == 0 {
+		return ""
+	}
+	return ids[v.rng.Intn(len(ids))]
 }
 
-// 创建新游戏
-func NewWerewolfGame() *WerewolfGame {
-	return &WerewolfGame{
-		Players:         []*Player{},
-		Events:          []GameEvent{},
-		DayCount:        1,
-		Sheriff:         nil,
-		SheriffElect:    false,
-		WolfKillTarget:  "",
-		Antidote:        false,
-		HumanWolfVotes:  make(map[string]int),
-		PoisonedPlayers: []string{},
-		Logs:            []string{},
+// 查找座位
+func (v *AIView) Seat(id string) *SeatView {
+	for i := range v.Seats {
+		if v.Seats[i].ID == id {
+			return &v.Seats[i]
+		}
 	}
+	return nil
 }
 
-// 添加日志
-func (g *WerewolfGame) Log(message string) {
-	g.mu.Lock()
-	defer g.mu.Unlock()
-	fmt.Println(message)
-	g.Logs = append(g.Logs, message)
+// 自己是否是狼人
+func (v *AIView) IsWolf() bool {
+	return isWolfRole(v.Self.Role)
 }
 
-// 随机分配角色
-func (g *WerewolfGame) RandomAllocate() {
-	numPlayers := len(g.Players)
-	roles := []Role{}
+// 是否是自己已知的狼队友
+func (v *AIView) Teammate(id string) bool {
+	seat := v.Seat(id)
+	return v.IsWolf() && seat != nil && seat.ID != v.Self.ID && isWolfRole(seat.Role)
+}
 
-	// 分配狼人
-	werewolfCount := 1
-	if numPlayers >= 5 {
-		werewolfCount = 2
-	}
-	for i := 0; i < werewolfCount; i++ {
-		roles = append(roles, NewWolf())
+// 除自己外的存活玩家
+func (v *AIView) Others() []string {
+	ids := []string{}
+	for _, seat := range v.Seats {
+		if seat.Alive && seat.ID != v.Self.ID {
+			ids = append(ids, seat.ID)
+		}
 	}
+	return ids
+}
 
-	// 分配预言家和女巫
-	roles = append(roles, NewSeer())
-	witch := NewWitch()
-	witch.Game = g
-	roles = append(roles, witch)
-
-	// 分配剩余平民
-	neededVillagers := numPlayers - len(roles)
-	for i := 0; i < neededVillagers; i++ {
-		roles = append(roles, NewVillager())
+// 过滤ID列表
+func (v *AIView) filter(ids []string, keep func(id string) bool) []string {
+	kept := []string{}
+	for _, id := range ids {
+		if keep(id) {
+			kept = append(kept, id)
+		}
 	}
+	return kept
+}
 
-	rand.Shuffle(len(roles), func(i, j int) {
-		roles[i], roles[j] = roles[j], roles[i]
-	})
+// 自己的查验结果
+func (v *AIView) Checks() map[string]string {
+	return v.Knowledge.Checks
+}
 
-	// 分配给玩家
-	for i, player := range g.Players {
-		if i < len(roles) {
-			player.Role = roles[i]
-		} else {
-			player.Role = NewVillager()
+// 公开起跳预言家的玩家及其报出的查验结果
+func (v *AIView) Claims() map[string]map[string]string {
+	claims := map[string]map[string]string{}
+	for _, e := range v.Events {
+		if e.Type != EventClaim {
+			continue
+		}
+		checks, _ := e.Data["checks"].(map[string]string)
+		if claims[e.Actor] == nil {
+			claims[e.Actor] = map[string]string{}
+		}
+		for id, result := range checks {
+			claims[e.Actor][id] = result
 		}
 	}
+	return claims
+}
 
-	// 记录分配情况
-	g.Log("=== 角色分配 ===")
-	for _, p := range g.Players {
-		g.Log(fmt.Sprintf("%s 的角色是 %s", p.Name, p.Role.GetName()))
+// 起跳的预言家中仍存活的一人
+func (v *AIView) ClaimedSeer() string {
+	for _, seat := range v.Seats {
+		if _, ok := v.Claims()[seat.ID]; ok && seat.Alive && seat.ID != v.Self.ID {
+			return seat.ID
+		}
 	}
+	return ""
 }
 
-// 添加玩家
-func (g *WerewolfGame) AddPlayer(player *Player) {
-	g.Players = append(g.Players, player)
+// 自己是否已被起跳的预言家公开查杀
+func (v *AIView) Exposed() bool {
+	for _, checks := range v.Claims() {
+		if checks[v.Self.ID] == FactionWolf {
+			return true
+		}
+	}
+	return false
 }
 
-// 选举警长
-func (g *WerewolfGame) ElectSheriff() {
-	rounds := 3
-	for roundNumber := 1; roundNumber <= rounds; roundNumber++ {
-		maxVotes := float64(0)
-		for _, p := range g.Players {
-			if p.Alive && p.Votes > maxVotes {
-				maxVotes = p.Votes
+// 自己认为是狼人的存活玩家：自己的查验结果，以及好人相信的预言家报出的查验结果
+func (v *AIView) SuspectedWolves() []string {
+	suspects := map[string]bool{}
+	for id, result := range v.Checks() {
+		suspects[id] = result == FactionWolf
+	}
+	if !v.IsWolf() {
+		for _, checks := range v.Claims() {
+			for id, result := range checks {
+				if _, known := suspects[id]; !known && result == FactionWolf {
+					suspects[id] = true
+				}
 			}
 		}
+	}
+	return v.filter(v.Others(), func(id string) bool { return suspects[id] })
+}
 
-		candidates := []*Player{}
-		for _, p := range g.Players {
-			if p.Alive && p.Votes == maxVotes {
-				candidates = append(candidates, p)
+// 历次放逐投票中每名玩家累计被投的票数
+func (v *AIView) VotesAgainst() map[string]float64 {
+	votes := map[string]float64{}
+	for _, e := range v.Events {
+		if e.Type != EventExile {
+			continue
+		}
+		tally, _ := e.Data["votes"].(map[string]interface{})
+		for id, count := range tally {
+			if n, ok := count.(float64); ok {
+				votes[id] += n
 			}
 		}
+	}
+	return votes
+}
 
-		if len(candidates) == 1 {
-			g.Sheriff = candidates[0]
-			g.Sheriff.Sheriff = true
-			g.Log(fmt.Sprintf("\n%s 当选警长！", g.Sheriff.Name))
-			g.resetVotes()
-			return
+// 自己认为是好人的存活玩家：查验为好人、起跳的预言家和翻牌的白痴
+func (v *AIView) TrustedGood() []string {
+	trusted := map[string]bool{}
+	for id, result := range v.Checks() {
+		trusted[id] = result == FactionGood
+	}
+	if !v.IsWolf() {
+		for claimant, checks := range v.Claims() {
+			trusted[claimant] = true
+			for id, result := range checks {
+				if result == FactionGood {
+					trusted[id] = true
+				}
+			}
 		}
+	}
+	for _, id := range v.Knowledge.Revealed {
+		trusted[id] = true
+	}
+	return v.filter(v.Others(), func(id string) bool { return trusted[id] })
+}
+
+// 从模板中生成没有信息时的发言，随机提到一名存活玩家
+func (v *AIView) defaultSpeech() LocalText {
+	var mentioned interface{} = Text("ai.everyone")
+	if seat := v.Seat(v.pick(v.Others())); seat != nil {
+		mentioned = seat.Name
+	}
+	return Text(fmt.Sprintf("ai.speech.%d", v.rng.Intn(aiSpeechLines)), mentioned)
+}
 
-		g.Log(fmt.Sprintf("第 %d 轮选举没有选出警长。", roundNumber))
-		g.resetVotes()
+// 生成默认遗言，狼人会伪装成好人
+func (v *AIView) defaultLastWords() LocalText {
+	switch {
+	case v.IsWolf():
+		return Text("ai.last_words.wolf")
+	case v.Self.Role != RoleVillager:
+		return Text("ai.last_words.god", Text("role."+v.Self.Role))
+	default:
+		return Text("ai.last_words.plain")
 	}
+}
+
+// 随机策略，所有决策都随机做出
+type RandomStrategy struct{}
 
-	g.Log("警长选举失败，本局没有警长")
+func (r *RandomStrategy) Name() string {
+	return StrategyRandom
 }
 
-// 重置投票
-func (g *WerewolfGame) resetVotes() {
-	for _, p := range g.Players {
-		p.Votes = 0
+func (r *RandomStrategy) WolfKill(v *AIView) string {
+	return v.pick(v.filter(v.Others(), func(id string) bool { return !v.Teammate(id) }))
+}
+
+func (r *RandomStrategy) Guard(v *AIView) string {
+	candidates := append(v.Others(), v.Self.ID)
+	return v.pick(v.filter(candidates, func(id string) bool { return id != v.LastProtected }))
+}
+
+func (r *RandomStrategy) Witch(v *AIView) (bool, string) {
+	save := v.CanSave && v.rng.Float64() < 0.7
+	poison := ""
+	if v.CanPoison && v.rng.Float64() < 0.3 {
+		poison = v.pick(v.Others())
 	}
+	return save, poison
 }
 
-// 投票出局
-func (g *WerewolfGame) Vote() {
-	alivePlayers := []*Player{}
-	for _, p := range g.Players {
-		if p.Alive {
-			alivePlayers = append(alivePlayers, p)
-		}
+func (r *RandomStrategy) SeerCheck(v *AIView) string {
+	return v.pick(v.Others())
+}
+
+func (r *RandomStrategy) CupidLink(v *AIView) []string {
+	candidates := append(v.Others(), v.Self.ID)
+	if len(candidates) < 2 {
+		return nil
 	}
+	perm := v.rng.Perm(len(candidates))
+	return []string{candidates[perm[0]], candidates[perm[1]]}
+}
 
-	if len(alivePlayers) == 0 {
-		return
+func (r *RandomStrategy) RunForSheriff(v *AIView) bool {
+	return v.rng.Intn(2) == 0
+}
+
+func (r *RandomStrategy) WithdrawSheriff(v *AIView) bool {
+	return v.rng.Intn(5) == 0
+}
+
+func (r *RandomStrategy) SheriffVote(v *AIView, candidates []string) string {
+	return v.pick(candidates)
+}
+
+func (r *RandomStrategy) DayVote(v *AIView, candidates []string) string {
+	return v.pick(v.filter(candidates, func(id string) bool { return id != v.Self.ID }))
+}
+
+func (r *RandomStrategy) Speech(v *AIView) (LocalText, map[string]string) {
+	return v.defaultSpeech(), nil
+}
+
+func (r *RandomStrategy) LastWords(v *AIView) (LocalText, map[string]string) {
+	return v.defaultLastWords(), nil
+}
+
+func (r *RandomStrategy) SpeechDirection(v *AIView) string {
+	if v.rng.Intn(2) == 0 {
+		return "left"
 	}
+	return "right"
+}
 
-	maxVotes := float64(0)
-	for _, p := range alivePlayers {
-		if p.Votes > maxVotes {
-			maxVotes = p.Votes
+func (r *RandomStrategy) HunterShoot(v *AIView, candidates []string) string {
+	return v.pick(candidates)
+}
+
+func (r *RandomStrategy) BadgeHeir(v *AIView, candidates []string) string {
+	return v.pick(candidates)
+}
+
+// 只有白狼王会自爆，自爆时带走一名玩家
+func (r *RandomStrategy) Explode(v *AIView) bool {
+	return v.Self.Role == RoleWhiteWolfKing
+}
+
+func (r *RandomStrategy) WolfKingTarget(v *AIView, candidates []string) string {
+	candidates = v.filter(candidates, func(id string) bool { return !v.Teammate(id) })
+	for _, id := range candidates {
+		if v.Seat(id).Sheriff {
+			return id
 		}
 	}
+	return v.pick(candidates)
+}
 
-	candidates := []*Player{}
-	for _, p := range alivePlayers {
-		if p.Votes == maxVotes {
-			candidates = append(candidates, p)
+// 启发式策略：狼人统一刀口且不投队友，预言家起跳报查验，女巫尽早用解药，
+// 好人跟随预言家的查验结果投票，没有信息时退化为随机策略
+type HeuristicStrategy struct {
+	RandomStrategy
+}
+
+func (h *HeuristicStrategy) Name() string {
+	return StrategyHeuristic
+}
+
+// 跟随队友当晚已经投出的刀口，否则优先刀起跳的预言家和警长
+func (h *HeuristicStrategy) WolfKill(v *AIView) string {
+	targets := v.filter(v.Others(), func(id string) bool { return !v.Teammate(id) })
+	for i := len(v.Events) - 1; i >= 0; i-- {
+		e := v.Events[i]
+		if e.Type == EventWolfVote && e.Day == v.Day && e.Actor != v.Self.ID && containsString(targets, e.Target) {
+			return e.Target
 		}
 	}
-
-	if len(candidates) == 1 {
-		killed := candidates[0]
-		killed.Alive = false
-		g.Log(fmt.Sprintf("\n%s 被投票出局", killed.Name))
-		if killed.Sheriff {
-			g.TransferSheriff()
+	if seer := v.ClaimedSeer(); containsString(targets, seer) {
+		return seer
+	}
+	for _, id := range targets {
+		if v.Seat(id).Sheriff {
+			return id
 		}
-	} else {
-		g.Log("平票，无人出局")
 	}
-
-	g.resetVotes()
+	return v.pick(targets)
 }
 
-// 转移警长
-func (g *WerewolfGame) TransferSheriff() {
-	candidates := []*Player{}
-	for _, p := range g.Players {
-		if p.Alive && !p.Sheriff {
-			candidates = append(candidates, p)
+// 优先守护起跳的预言家，其次是警长
+func (h *HeuristicStrategy) Guard(v *AIView) string {
+	for _, id := range []string{v.ClaimedSeer(), h.sheriff(v)} {
+		if id != "" && id != v.LastProtected {
+			return id
 		}
 	}
+	return h.RandomStrategy.Guard(v)
+}
 
-	if len(candidates) > 0 {
-		newSheriff := candidates[rand.Intn(len(candidates))]
-		g.Sheriff = newSheriff
-		newSheriff.Sheriff = true
-		g.Log(fmt.Sprintf("%s 成为新警长！", newSheriff.Name))
-	} else {
-		g.Log("没有合适玩家继承警徽")
+// 能救就救，毒药只留给确认的狼人
+func (h *HeuristicStrategy) Witch(v *AIView) (bool, string) {
+	poison := ""
+	if v.CanPoison {
+		poison = v.pick(v.SuspectedWolves())
 	}
+	return v.CanSave, poison
 }
 
-// 检查游戏是否结束
-func (g *WerewolfGame) CheckGameEnd() bool {
-	aliveWerewolves := 0
-	aliveVillagers := 0
-
-	for _, p := range g.Players {
-		if p.Alive {
-			if p.IsWolf() {
-				aliveWerewolves++
-			} else {
-				aliveVillagers++
-			}
-		}
+// 优先查验没有验过的警长，其次随机查验没有验过的玩家
+func (h *HeuristicStrategy) SeerCheck(v *AIView) string {
+	checks := v.Checks()
+	unchecked := v.filter(v.Others(), func(id string) bool {
+		_, checked := checks[id]
+		return !checked
+	})
+	if sheriff := h.sheriff(v); containsString(unchecked, sheriff) {
+		return sheriff
 	}
+	if target := v.pick(unchecked); target != "" {
+		return target
+	}
+	return h.RandomStrategy.SeerCheck(v)
+}
 
-	g.Log(fmt.Sprintf("当前存活情况: %d 狼人, %d 好人", aliveWerewolves, aliveVillagers))
-
-	if aliveWerewolves == 0 {
-		g.Log("\n好人阵营胜利！")
-		g.WinnerIsWerewolf = false
-		return true
-	} else if aliveWerewolves >= aliveVillagers {
-		g.Log("\n狼人阵营胜利！")
-		g.WinnerIsWerewolf = true
+// 预言家总是上警，其他玩家少量上警
+func (h *HeuristicStrategy) RunForSheriff(v *AIView) bool {
+	if v.Self.Role == RoleSeer {
 		return true
 	}
-	return false
+	return v.rng.Float64() < 0.3
 }
 
-// 白天行动
-func (g *WerewolfGame) DayActions() {
-	g.Log(fmt.Sprintf("第 %d 天白天", g.DayCount))
-
-	// 宣布夜晚死亡的玩家
-	if g.WolfKillTarget != "" {
-		var target *Player
-		for _, p := range g.Players {
-			if p.Name == g.WolfKillTarget {
-				target = p
-				break
-			}
-		}
-
-		if target != nil && !target.Alive {
-			g.Log(fmt.Sprintf("\n%s 昨晚被狼人杀死了", target.Name))
-
-			// 如果是警长，需要转移警徽
-			if target.Sheriff {
-				g.TransferSheriff()
-			}
+func (h *HeuristicStrategy) WithdrawSheriff(v *AIView) bool {
+	return false
+}
 
-			// 猎人死亡可能触发带走一个人
-			if target.IsHunter() {
-				g.Log(fmt.Sprintf("%s 是猎人，可以带走一个人", target.Name))
-				// 猎人逻辑在PlayerDayAction中处理
-			}
+// 狼人投给队友，好人投给起跳的预言家，避开怀疑的狼人
+func (h *HeuristicStrategy) SheriffVote(v *AIView, candidates []string) string {
+	if v.IsWolf() {
+		if mates := v.filter(candidates, v.Teammate); len(mates) > 0 {
+			return v.pick(mates)
 		}
+		return v.pick(candidates)
 	}
+	if seer := v.ClaimedSeer(); containsString(candidates, seer) {
+		return seer
+	}
+	suspects := v.SuspectedWolves()
+	if safe := v.filter(candidates, func(id string) bool { return !containsString(suspects, id) }); len(safe) > 0 {
+		return v.pick(safe)
+	}
+	return v.pick(candidates)
+}
 
-	// 处理被毒的玩家
-	for _, poisonedName := range g.PoisonedPlayers {
-		var poisoned *Player
-		for _, p := range g.Players {
-			if p.Name == poisonedName && p.Alive {
-				poisoned = p
-				break
-			}
+// 狼人从不投队友并优先投起跳的预言家，好人投怀疑的狼人并避开信任的好人
+func (h *HeuristicStrategy) DayVote(v *AIView, candidates []string) string {
+	candidates = v.filter(candidates, func(id string) bool { return id != v.Self.ID && id != v.Lover })
+	if v.IsWolf() {
+		targets := v.filter(candidates, func(id string) bool { return !v.Teammate(id) })
+		if seer := v.ClaimedSeer(); containsString(targets, seer) {
+			return seer
 		}
+		return v.pick(targets)
+	}
 
-		if poisoned != nil {
-			poisoned.Alive = false
-			g.Log(fmt.Sprintf("%s 被毒死了", poisoned.Name))
+	if suspects := v.filter(candidates, func(id string) bool { return containsString(v.SuspectedWolves(), id) }); len(suspects) > 0 {
+		return v.pick(suspects)
+	}
+	trusted := v.TrustedGood()
+	if others := v.filter(candidates, func(id string) bool { return !containsString(trusted, id) }); len(others) > 0 {
+		return v.pick(others)
+	}
+	return v.pick(candidates)
+}
 
-			if poisoned.Sheriff {
-				g.TransferSheriff()
-			}
+// 预言家起跳报出全部查验结果，好人号召投出怀疑的狼人
+func (h *HeuristicStrategy) Speech(v *AIView) (LocalText, map[string]string) {
+	if v.Self.Role == RoleSeer {
+		return h.seerClaim(v)
+	}
+	if !v.IsWolf() {
+		if suspect := v.Seat(v.pick(v.SuspectedWolves())); suspect != nil {
+			return Text("ai.follow_seer", suspect.Name), nil
 		}
 	}
-
-	g.PoisonedPlayers = []string{}
-	g.WolfKillTarget = "" // 清空击杀目标
-	g.DayCount++
+	return v.defaultSpeech(), nil
 }
 
-// 夜晚行动
-func (g *WerewolfGame) NightActions() {
-	g.Log(fmt.Sprintf("第 %d 天黑夜", g.DayCount))
-	g.WolfKillTarget = ""
+// 预言家在遗言中交代全部查验结果
+func (h *HeuristicStrategy) LastWords(v *AIView) (LocalText, map[string]string) {
+	if v.Self.Role == RoleSeer {
+		return h.seerClaim(v)
+	}
+	return v.defaultLastWords(), nil
+}
 
-	votes := make(map[string]int)
+// 猎人优先带走怀疑的狼人，否则带走放逐投票中被投票最多的玩家，不带走信任的好人和情侣
+func (h *HeuristicStrategy) HunterShoot(v *AIView, candidates []string) string {
+	suspects := v.SuspectedWolves()
+	if target := v.pick(v.filter(candidates, func(id string) bool { return containsString(suspects, id) })); target != "" {
+		return target
+	}
 
-	// AI狼人投票
-	for _, player := range g.Players {
-		if player.IsWolf() && player.Alive && player.IsAI {
-			actionResult := player.NightAction(g.Players)
-			if actionResult != nil {
-				if target, ok := actionResult["vote"].(string); ok {
-					votes[target]++
-					g.Log(fmt.Sprintf("%s votes for %s", player.Name, target))
-				}
-			}
+	trusted := v.TrustedGood()
+	votes := v.VotesAgainst()
+	most := []string{}
+	for _, id := range candidates {
+		switch {
+		case id == v.Lover || containsString(trusted, id):
+		case len(most) == 0 || votes[id] > votes[most[0]]:
+			most = []string{id}
+		case votes[id] == votes[most[0]]:
+			most = append(most, id)
 		}
 	}
+	return v.pick(most)
+}
 
-	// 加入人类狼人的投票
-	for targetName, count := range g.HumanWolfVotes {
-		votes[targetName] += count
+// 警徽交给最信任的玩家：情侣、狼队友、验过的好人或翻牌的白痴，否则随机交出
+func (h *HeuristicStrategy) BadgeHeir(v *AIView, candidates []string) string {
+	if containsString(candidates, v.Lover) {
+		return v.Lover
+	}
+	if v.IsWolf() {
+		if mates := v.filter(candidates, v.Teammate); len(mates) > 0 {
+			return v.pick(mates)
+		}
+		return v.pick(candidates)
 	}
+	trusted := v.TrustedGood()
+	if good := v.filter(candidates, func(id string) bool { return containsString(trusted, id) }); len(good) > 0 {
+		return v.pick(good)
+	}
+	return v.pick(candidates)
+}
 
-	g.Log(fmt.Sprintf("狼人投票结果: %v", votes))
+// 白狼王即将被放逐时自爆；普通狼人只有已被预言家查杀时才自爆，避免留下遗言
+func (h *HeuristicStrategy) Explode(v *AIView) bool {
+	return v.Self.Role == RoleWhiteWolfKing || v.Exposed()
+}
 
-	if len(votes) > 0 {
-		maxVotes := 0
-		for _, count := range votes {
-			if count > maxVotes {
-				maxVotes = count
-			}
-		}
+// 白狼王优先带走起跳的预言家，其次是警长
+func (h *HeuristicStrategy) WolfKingTarget(v *AIView, candidates []string) string {
+	if seer := v.ClaimedSeer(); containsString(candidates, seer) && !v.Teammate(seer) {
+		return seer
+	}
+	return h.RandomStrategy.WolfKingTarget(v, candidates)
+}
 
-		candidates := []string{}
-		for name, count := range votes {
-			if count == maxVotes {
-				candidates = append(candidates, name)
+// 预言家报出全部查验结果，有查杀时号召投出
+func (h *HeuristicStrategy) seerClaim(v *AIView) (LocalText, map[string]string) {
+	checks := v.Checks()
+	if len(checks) == 0 {
+		return Text("ai.seer_no_info"), nil
+	}
+
+	items := []LocalText{}
+	wolf := ""
+	for _, seat := range v.Seats {
+		if result, ok := checks[seat.ID]; ok {
+			items = append(items, Text("ai.check_item", seat.Name, Text("check."+result)))
+			if result == FactionWolf && seat.Alive {
+				wolf = seat.Name
 			}
 		}
+	}
+	conclusion := Text("ai.seer_no_wolf")
+	if wolf != "" {
+		conclusion = Text("ai.seer_push", wolf)
+	}
+	return Text("ai.seer_claim", items, conclusion), checks
+}
 
-		if len(candidates) > 0 {
-			g.WolfKillTarget = candidates[rand.Intn(len(candidates))]
+// 存活的警长
+func (h *HeuristicStrategy) sheriff(v *AIView) string {
+	for _, seat := range v.Seats {
+		if seat.Alive && seat.Sheriff && seat.ID != v.Self.ID {
+			return seat.ID
 		}
 	}
+	return ""
+}
+
+// ============ 阶段定义 ============
+
+// 阶段名称
+const (
+	PhaseNightfall  = "nightfall"
+	PhaseNightCupid = "night-cupid"
+	PhaseNightGuard = "night-guard"
+	PhaseNightWolf  = "night-wolf"
+	PhaseNightWitch = "night-witch"
+	PhaseNightSeer  = "night-seer"
+	PhaseDawn       = "dawn"
+	PhaseSheriff    = "sheriff"
+	PhaseAnnounce   = "announce"
+	PhaseDiscussion = "discussion"
+	PhaseVote       = "vote"
+	PhasePK         = "pk"
+	PhaseLastWords  = "last-words"
+	PhaseGameOver   = "game-over"
+)
+
+// 游戏阶段，Run 返回下一阶段的名称，返回空字符串时进入默认的下一阶段
+type Phase struct {
+	Name string
+	Next []string // 允许转入的阶段，第一个为默认阶段
+	Run  func() string
+}
 
-	if g.WolfKillTarget != "" {
-		g.Log(fmt.Sprintf("今晚狼人选择了击杀 %s", g.WolfKillTarget))
+// 阶段状态机
+type PhaseMachine struct {
+	phases   map[string]*Phase
+	Current  string
+	Previous string
+}
+
+// 创建阶段状态机
+func NewPhaseMachine(start string) *PhaseMachine {
+	return &PhaseMachine{
+		phases:  make(map[string]*Phase),
+		Current: start,
 	}
+}
 
-	g.HumanWolfVotes = make(map[string]int)
+// 注册阶段，同名阶段会被替换
+func (m *PhaseMachine) Register(phase *Phase) {
+	m.phases[phase.Name] = phase
 }
 
-// 完成夜晚行动，处理最终结果
-func (g *WerewolfGame) FinishNight() {
-	// 处理狼人杀人
-	if g.WolfKillTarget != "" && !g.Antidote {
-		var target *Player
-		for _, p := range g.Players {
-			if p.Name == g.WolfKillTarget {
-				target = p
-				break
-			}
-		}
+// 获取阶段定义
+func (m *PhaseMachine) Get(name string) *Phase {
+	return m.phases[name]
+}
 
-		if target != nil {
-			target.Alive = false // 标记为死亡，但不立即宣布
-			g.Log(fmt.Sprintf("狼人选择了击杀 %s", target.Name))
-		}
-	} else if g.WolfKillTarget != "" && g.Antidote {
-		g.Log(fmt.Sprintf("%s 被女巫救活了", g.WolfKillTarget))
-		g.WolfKillTarget = "" // 清空击杀目标，表示已被救活
+// 转入下一阶段，未声明的转换会被拒绝并转入默认阶段
+func (m *PhaseMachine) Transition(next string) error {
+	phase, ok := m.phases[m.Current]
+	if !ok {
+		return fmt.Errorf("当前阶段 %s 未注册", m.Current)
+	}
+	if len(phase.Next) == 0 {
+		return fmt.Errorf("阶段 %s 没有后续阶段", m.Current)
 	}
 
-	// 收集女巫毒杀的目标
-	for _, player := range g.Players {
-		if player.IsWitch() {
-			witch, ok := player.Role.(*Witch)
-			if ok && witch.PoisonedTarget != "" {
-				g.PoisonedPlayers = append(g.PoisonedPlayers, witch.PoisonedTarget)
-				g.Log(fmt.Sprintf("女巫对 %s 使用了毒药，将在明天白天死亡", witch.PoisonedTarget))
-				witch.PoisonedTarget = ""
-			}
-		}
+	var err error
+	if next == "" {
+		next = phase.Next[0]
+	} else if !containsString(phase.Next, next) {
+		err = fmt.Errorf("不允许从 %s 转入 %s", m.Current, next)
+		next = phase.Next[0]
 	}
 
-	g.Antidote = false
+	m.Previous = m.Current
+	m.Current = next
+	return err
 }
 
-// ============ 游戏结果定义 ============
-
-// GameResult 存储游戏结果
-type GameResult struct {
-	GameID         int
-	Port           int
-	Duration       time.Duration
-	WinningFaction string
-	Players        []PlayerInfo
-	Logs           []string
-	Error          error
+// 判断字符串切片中是否包含指定值
+func containsString(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
 }
 
-type PlayerInfo struct {
-	Name   string
-	Role   string
-	Alive  bool
-	IsWolf bool
+// 判断女巫今晚能否对狼人刀口使用解药，按自救规则限制女巫救自己
+func (g *WerewolfGame) CanWitchSave(witchPlayer *Player) bool {
+	witch, ok := witchPlayer.Role.(*Witch)
+	victim := g.Night.Target(ActionKill)
+	if !ok || !witch.HasAntidote || victim == "" {
+		return false
+	}
+	if victim != witchPlayer.ID {
+		return true
+	}
+	switch g.Options.Witch.SelfSave {
+	case SelfSaveAlways:
+		return true
+	case SelfSaveNever:
+		return false
+	default:
+		return g.DayCount == 1
+	}
 }
 
-// ============ 游戏实例定义 ============
+// 记录女巫对当晚刀口使用解药
+func (g *WerewolfGame) RecordWitchSave(witchPlayer, target *Player) {
+	g.Record(GameEvent{Type: EventWitchSave, Actor: witchPlayer.ID, Target: target.ID, Visibility: VisibilityPlayer, Viewer: witchPlayer.ID})
+}
 
-// GameInstance 表示一个游戏实例
-type GameInstance struct {
-	GameID    int
-	Port      int
-	Server    *GameServer
-	IsRunning bool
-	StartTime time.Time
-	Result    *GameResult
-	mu        sync.Mutex
+// 记录女巫使用毒药
+func (g *WerewolfGame) RecordWitchPoison(witchPlayer, target *Player) {
+	g.Record(GameEvent{Type: EventWitchPoison, Actor: witchPlayer.ID, Target: target.ID, Visibility: VisibilityPlayer, Viewer: witchPlayer.ID})
 }
 
-// ============ 游戏服务器定义 ============
+// 记录预言家查验结果
+func (g *WerewolfGame) RecordSeerCheck(seer, target *Player, result string) {
+	g.Record(GameEvent{
+		Type:       EventSeerCheck,
+		Actor:      seer.ID,
+		Target:     target.ID,
+		Visibility: VisibilityPlayer,
+		Viewer:     seer.ID,
+		Data:       map[string]interface{}{"result": result},
+	})
+}
 
-// 客户端连接
-type ClientConnection struct {
-	conn   net.Conn
-	player *Player
+// 记录玩家在发言或遗言中公开起跳的身份和查验结果
+func (g *WerewolfGame) RecordClaim(player *Player, role string, checks map[string]string) {
+	g.Record(GameEvent{
+		Type:       EventClaim,
+		Actor:      player.ID,
+		Visibility: VisibilityPublic,
+		Data:       map[string]interface{}{"role": role, "checks": checks},
+	})
 }
 
-// 游戏服务器
-type GameServer struct {
-	game           *WerewolfGame
-	clients        []*ClientConnection
-	voteLock       sync.Mutex
-	numRealPlayers int
-	numAIPlayers   int
-	port           int
-	host           string
-	listener       net.Listener
-	running        bool
-	result         *GameResult
+// 判断女巫今晚能否看到狼人刀口，解药用完后按规则决定
+func (g *WerewolfGame) WitchSeesVictim(witch *Witch) bool {
+	return witch.HasAntidote || g.Options.Witch.SeeVictim
 }
 
-// 创建新服务器
-func NewGameServer() *GameServer {
-	return &GameServer{
-		game:     NewWerewolfGame(),
-		clients:  []*ClientConnection{},
-		voteLock: sync.Mutex{},
-		running:  false,
+// 判断玩家切片中是否包含指定玩家
+func containsPlayer(list []*Player, p *Player) bool {
+	for _, item := range list {
+		if item == p {
+			return true
+		}
 	}
+	return false
 }
 
-// 启动服务器 - 现在接受参数并返回结果
-func (s *GameServer) Start(host string, port int, numRealPlayers, numAIPlayers int) (*GameResult, error) {
-	s.host = host
-	s.port = port
-	s.numRealPlayers = numRealPlayers
-	s.numAIPlayers = numAIPlayers
+// 获取玩家名字列表，用于日志
+func playerNames(players []*Player) []string {
+	names := []string{}
+	for _, p := range players {
+		names = append(names, p.Name)
+	}
+	return names
+}
 
-	// 创建结果对象
-	result := &GameResult{
-		Port:    port,
-		Players: []PlayerInfo{},
+// 获取玩家ID列表，用于协议消息
+func playerIDs(players []*Player) []string {
+	ids := []string{}
+	for _, p := range players {
+		ids = append(ids, p.ID)
 	}
-	s.result = result
+	return ids
+}
 
-	// 设置开始时间
-	startTime := time.Now()
+// ============ 事件定义 ============
+
+// 事件类型
+const (
+	EventRoleAssigned   = "role_assigned"   // 分配角色
+	EventLoversLinked   = "lovers_linked"   // 丘比特连接情侣
+	EventGuardProtect   = "guard_protect"   // 守卫守护
+	EventWolfVote       = "wolf_vote"       // 狼人投票
+	EventWolfChat       = "wolf_chat"       // 狼人夜间私聊
+	EventWolfKill       = "wolf_kill"       // 狼人确定击杀目标
+	EventWitchSave      = "witch_save"      // 女巫使用解药
+	EventWitchPoison    = "witch_poison"    // 女巫使用毒药
+	EventSeerCheck      = "seer_check"      // 预言家查验
+	EventSheriffElected = "sheriff_elected" // 警长当选
+	EventBadgeTransfer  = "badge_transfer"  // 警徽移交或撕毁
+	EventExile          = "exile"           // 放逐投票结果
+	EventDeath          = "death"           // 玩家死亡
+	EventHunterShot     = "hunter_shot"     // 猎人开枪
+	EventSelfDestruct   = "self_destruct"   // 狼人自爆
+	EventClaim          = "claim"           // 玩家公开起跳身份并报出查验结果
+)
 
-	// 创建监听器
-	addr := fmt.Sprintf("%s:%d", host, port)
-	listener, err := net.Listen("tcp", addr)
+// 事件可见范围
+const (
+	VisibilityPublic = "public" // 所有玩家可见
+	VisibilityWolves = "wolves" // 只有狼人可见
+	VisibilityPlayer = "player" // 只有 Viewer 指定的玩家可见
+	VisibilityGod    = "god"    // 只有上帝视角可见
+)
+
+// 游戏事件，玩家均以ID表示
+type GameEvent struct {
+	Type       string                 `json:"type"`
+	Time       time.Time              `json:"time"`
+	Day        int                    `json:"day"`
+	Actor      string                 `json:"actor,omitempty"`
+	Target     string                 `json:"target,omitempty"`
+	Visibility string                 `json:"visibility"`
+	Viewer     string                 `json:"viewer,omitempty"` // Visibility 为 player 时可见的玩家ID
+	Data       map[string]interface{} `json:"data,omitempty"`
+}
+
+// 判断事件对玩家是否可见
+func (e GameEvent) VisibleTo(playerID string, isWolf bool) bool {
+	switch e.Visibility {
+	case VisibilityPublic:
+		return true
+	case VisibilityWolves:
+		return isWolf
+	case VisibilityPlayer:
+		return e.Viewer == playerID
+	default:
+		return false
+	}
+}
+
+// 按可见范围过滤事件
+func filterEvents(events []GameEvent, playerID string, isWolf bool) []GameEvent {
+	visible := []GameEvent{}
+	for _, e := range events {
+		if e.VisibleTo(playerID, isWolf) {
+			visible = append(visible, e)
+		}
+	}
+	return visible
+}
+
+// ============ 玩家知识定义 ============
+
+// 女巫剩余的药剂
+type PotionStatus struct {
+	Antidote bool `json:"antidote"`
+	Poison   bool `json:"poison"`
+}
+
+// 玩家掌握的信息，游戏在事件发生时按可见范围更新
+type Knowledge struct {
+	Roles    map[string]string `json:"roles"`             // 已知身份的玩家ID -> 角色
+	Revealed []string          `json:"revealed"`          // 公开翻牌的玩家ID
+	Checks   map[string]string `json:"checks,omitempty"`  // 预言家的查验结果
+	Victims  map[int]string    `json:"victims,omitempty"` // 女巫每晚看到的刀口，按天数记录
+	Potions  *PotionStatus     `json:"potions,omitempty"` // 女巫剩余的药剂
+	Lover    string            `json:"lover,omitempty"`   // 情侣的ID
+	Linked   []string          `json:"linked,omitempty"`  // 丘比特连接的两名情侣的ID
+	mu       sync.Mutex
+}
+
+// 创建空的玩家知识
+func NewKnowledge() *Knowledge {
+	return &Knowledge{
+		Roles:    map[string]string{},
+		Revealed: []string{},
+		Checks:   map[string]string{},
+		Victims:  map[int]string{},
+	}
+}
+
+// 得知一名玩家的身份
+func (k *Knowledge) LearnRole(id, role string) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.Roles[id] = role
+}
+
+// 得知一名玩家公开翻牌的身份
+func (k *Knowledge) Reveal(id, role string) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.Roles[id] = role
+	if !containsString(k.Revealed, id) {
+		k.Revealed = append(k.Revealed, id)
+	}
+}
+
+// 记录预言家的查验结果
+func (k *Knowledge) RecordCheck(id, result string) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.Checks[id] = result
+}
+
+// 记录女巫看到的当晚刀口
+func (k *Knowledge) SeeVictim(day int, id string) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.Victims[day] = id
+}
+
+// 更新女巫剩余的药剂
+func (k *Knowledge) SetPotions(antidote, poison bool) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.Potions = &PotionStatus{Antidote: antidote, Poison: poison}
+}
+
+// 记录情侣
+func (k *Knowledge) SetLover(id string) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.Lover = id
+}
+
+// 丘比特记录自己连接的情侣
+func (k *Knowledge) SetLinked(ids []string) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.Linked = append([]string{}, ids...)
+}
+
+// 已知的玩家身份，不知道时返回空字符串
+func (k *Knowledge) RoleOf(id string) string {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return k.Roles[id]
+}
+
+// 复制当前的知识，用于发送给客户端和AI决策
+func (k *Knowledge) Snapshot() *Knowledge {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	snapshot := NewKnowledge()
+	for id, role := range k.Roles {
+		snapshot.Roles[id] = role
+	}
+	snapshot.Revealed = append(snapshot.Revealed, k.Revealed...)
+	for id, result := range k.Checks {
+		snapshot.Checks[id] = result
+	}
+	for day, id := range k.Victims {
+		snapshot.Victims[day] = id
+	}
+	if k.Potions != nil {
+		potions := *k.Potions
+		snapshot.Potions = &potions
+	}
+	snapshot.Lover = k.Lover
+	snapshot.Linked = append(snapshot.Linked, k.Linked...)
+	return snapshot
+}
+
+// ============ 夜间行动定义 ============
+
+// 夜间行动类型
+const (
+	ActionLink     = "link"      // 丘比特连接情侣
+	ActionGuard    = "guard"     // 守卫守护，目标为空表示空守
+	ActionWolfVote = "wolf_vote" // 单个狼人的投票，统计后得出狼队的击杀
+	ActionKill     = "kill"      // 狼队决定的击杀目标
+	ActionSave     = "save"      // 女巫对刀口使用解药
+	ActionPoison   = "poison"    // 女巫使用毒药
+	ActionCheck    = "check"     // 预言家查验
+)
+
+// 相互冲突的夜间行动在天亮时的结算顺序: 守卫守护、狼人击杀、女巫救人、女巫毒人。
+// 同一类型的行动按提交顺序结算，新角色只需在此处声明自己的位置；
+// 连接情侣和预言家查验不与其他行动冲突，提交时立即生效，不参与天亮结算
+var NightPriority = []string{ActionGuard, ActionKill, ActionSave, ActionPoison}
+
+// 一名玩家提交的夜间行动，狼队的击杀没有单独的行动者
+type NightAction struct {
+	Type    string
+	Actor   *Player
+	Targets []string
+}
+
+// 行动的第一个目标，没有目标时返回空字符串
+func (a NightAction) Target() string {
+	if len(a.Targets) == 0 {
+		return ""
+	}
+	return a.Targets[0]
+}
+
+// 一晚收集到的行动，相互冲突的行动在收集期间不修改游戏状态，天亮时由 ResolveNight 统一结算
+type NightActionSet struct {
+	actions []NightAction
+	mu      sync.Mutex
+}
+
+// 创建空的行动集合
+func NewNightActionSet() *NightActionSet {
+	return &NightActionSet{actions: []NightAction{}}
+}
+
+// 加入一个行动
+func (n *NightActionSet) Add(action NightAction) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.actions = append(n.actions, action)
+}
+
+// 清空行动，开始新的一晚
+func (n *NightActionSet) Reset() {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.actions = []NightAction{}
+}
+
+// 按提交顺序获取指定类型的行动
+func (n *NightActionSet) Of(kind string) []NightAction {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	actions := []NightAction{}
+	for _, a := range n.actions {
+		if a.Type == kind {
+			actions = append(actions, a)
+		}
+	}
+	return actions
+}
+
+// 指定类型行动的第一个目标，用于女巫查看刀口等收集期间的查询
+func (n *NightActionSet) Target(kind string) string {
+	if actions := n.Of(kind); len(actions) > 0 {
+		return actions[0].Target()
+	}
+	return ""
+}
+
+// 判断玩家是否已提交过指定类型的行动
+func (n *NightActionSet) Submitted(actor *Player, kind string) bool {
+	for _, a := range n.Of(kind) {
+		if a.Actor == actor {
+			return true
+		}
+	}
+	return false
+}
+
+// 夜间结算结果
+type NightOutcome struct {
+	Deaths []*Player // 当晚死亡的玩家，死因已记录，天亮公布时才正式出局
+}
+
+// ============ 游戏核心定义 ============
+
+// 狼人杀游戏
+type WerewolfGame struct {
+	Players       []*Player
+	DayCount      int
+	Sheriff       *Player
+	SheriffElect  bool
+	Night         *NightActionSet // 当晚收集的行动，天亮时统一结算
+	NightDeaths   []*Player       // 夜间结算出的死亡玩家，白天公布
+	LastDeath     *Player         // 最近死亡的玩家，用于确定发言起点
+	LoversFaction bool            // 情侣为人狼恋，与丘比特组成第三方阵营
+	Winner        string          // 获胜阵营
+	Options       GameOptions
+	Logs          []string
+	Events        []GameEvent
+	mu            sync.Mutex
+	rng           *rand.Rand    // 本局随机数源，只在游戏主流程中使用
+	updates       chan struct{} // 记录新事件时发出通知，用于向观战者推送
+}
+
+// 游戏规则选项
+type GameOptions struct {
+	Board         string // 板子名称，为空时使用默认板子
+	WinCondition  string // 胜利条件，为空时使用 parity
+	Seed          int64  // 随机种子，为0时自动生成
+	SpeechSeconds int    // 每名玩家的发言时间（秒），为0时使用默认值
+	WolfSeconds   int    // 狼人夜间商量刀口的时间（秒），为0时使用默认值
+	LastWords     string // 夜间死亡玩家的遗言规则，为空时使用 first_night
+	BadgeDefault  string // 真人警长移交警徽超时的默认处理，为空时使用 tear
+	Witch         WitchRules
+	AIStrategy    string   // AI玩家默认使用的策略，为空时使用 heuristic
+	AIStrategies  []string // 按加入顺序为每个AI座位单独指定的策略
+	GodToken      string   // 观战者获得上帝视角的口令，/create_game 总会填入请求值或随机生成的口令；为空时不开放上帝视角
+}
+
+// 女巫规则
+type WitchRules struct {
+	SelfSave    string // 自救规则，为空时使用 first_night
+	BothPotions bool   // 同一晚可以同时使用解药和毒药，默认每晚只能用一瓶
+	SeeVictim   bool   // 解药用完后仍能看到当晚的刀口，默认看不到
+}
+
+// 女巫自救规则
+const (
+	SelfSaveNever      = "never"       // 不能自救
+	SelfSaveFirstNight = "first_night" // 只有首夜可以自救
+	SelfSaveAlways     = "always"      // 任何一晚都可以自救
+)
+
+// 校验女巫自救规则，为空时返回默认的 first_night
+func ParseSelfSaveRule(name string) (string, error) {
+	switch name {
+	case "":
+		return SelfSaveFirstNight, nil
+	case SelfSaveNever, SelfSaveFirstNight, SelfSaveAlways:
+		return name, nil
+	default:
+		return "", fmt.Errorf("未知女巫自救规则: %s (可选 never, first_night, always)", name)
+	}
+}
+
+// 默认发言时间（秒）
+const DefaultSpeechSeconds = 60
+
+// 默认狼人夜间商量时间（秒）
+const DefaultWolfSeconds = 30
+
+// 夜间死亡玩家的遗言规则，白天被放逐的玩家总有遗言
+const (
+	LastWordsFirstNight = "first_night" // 只有首夜死亡的玩家有遗言
+	LastWordsAllNights  = "all_nights"  // 每晚死亡的玩家都有遗言
+	LastWordsNoNights   = "no_nights"   // 夜间死亡的玩家都没有遗言
+)
+
+// 校验遗言规则，为空时返回默认的 first_night
+func ParseLastWordsRule(name string) (string, error) {
+	switch name {
+	case "":
+		return LastWordsFirstNight, nil
+	case LastWordsFirstNight, LastWordsAllNights, LastWordsNoNights:
+		return name, nil
+	default:
+		return "", fmt.Errorf("未知遗言规则: %s (可选 first_night, all_nights, no_nights)", name)
+	}
+}
+
+// 胜利条件
+const (
+	WinByParity = "parity" // 狼人数量不少于好人即获胜
+	WinBySide   = "side"   // 屠边: 杀光所有神职或所有平民
+	WinByCity   = "city"   // 屠城: 杀光所有好人
+)
+
+// 校验胜利条件，为空时返回默认的 parity
+func ParseWinCondition(name string) (string, error) {
+	switch name {
+	case "":
+		return WinByParity, nil
+	case WinByParity, WinBySide, WinByCity:
+		return name, nil
+	default:
+		return "", fmt.Errorf("未知胜利条件: %s (可选 parity, side, city)", name)
+	}
+}
+
+// 真人警长移交警徽超时的默认处理
+const (
+	BadgeDefaultTear   = "tear"   // 撕毁警徽
+	BadgeDefaultRandom = "random" // 随机交给一名存活玩家
+)
+
+// 校验警徽默认处理，为空时返回默认的 tear
+func ParseBadgeDefault(name string) (string, error) {
+	switch name {
+	case "":
+		return BadgeDefaultTear, nil
+	case BadgeDefaultTear, BadgeDefaultRandom:
+		return name, nil
+	default:
+		return "", fmt.Errorf("未知警徽默认处理: %s (可选 tear, random)", name)
+	}
+}
+
+// 创建新游戏
+func NewWerewolfGame() *WerewolfGame {
+	return &WerewolfGame{
+		Players:      []*Player{},
+		DayCount:     1,
+		Sheriff:      nil,
+		SheriffElect: false,
+		Night:        NewNightActionSet(),
+		Logs:         []string{},
+		rng:          rand.New(rand.NewSource(time.Now().UnixNano())),
+		updates:      make(chan struct{}, 1),
+	}
+}
+
+// 使用指定种子重置随机数源，种子为0时自动生成，返回实际使用的种子
+func (g *WerewolfGame) SetSeed(seed int64) int64 {
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	g.Options.Seed = seed
+	g.rng = rand.New(rand.NewSource(seed))
+	return seed
+}
+
+// 添加日志
+func (g *WerewolfGame) Log(message string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	fmt.Println(message)
+	g.Logs = append(g.Logs, message)
+}
+
+// 记录事件，自动填写时间和天数
+func (g *WerewolfGame) Record(event GameEvent) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	event.Time = time.Now()
+	event.Day = g.DayCount
+	g.Events = append(g.Events, event)
+	g.learn(event)
+
+	// 通知有新事件，不阻塞游戏流程
+	select {
+	case g.updates <- struct{}{}:
+	default:
+	}
+}
+
+// 按事件更新能看到它的玩家的知识，调用时已持有 g.mu
+func (g *WerewolfGame) learn(e GameEvent) {
+	player := func(id string) *Player {
+		for _, p := range g.Players {
+			if p.ID == id {
+				return p
+			}
+		}
+		return nil
+	}
+
+	switch e.Type {
+	case EventRoleAssigned:
+		role, _ := e.Data["role"].(string)
+		for _, p := range g.Players {
+			if e.VisibleTo(p.ID, p.IsWolf()) {
+				p.Knowledge.LearnRole(e.Actor, role)
+			}
+		}
+		if actor := player(e.Actor); actor != nil {
+			g.syncPotions(actor)
+		}
+	case EventLoversLinked:
+		// 丘比特知道情侣是谁，情侣互相知道对方的身份
+		lovers, _ := e.Data["lovers"].([]string)
+		viewer := player(e.Viewer)
+		if viewer == nil {
+			break
+		}
+		if e.Viewer == e.Actor {
+			viewer.Knowledge.SetLinked(lovers)
+		}
+		for i, id := range lovers {
+			if other := player(lovers[len(lovers)-1-i]); id == e.Viewer && other != nil {
+				viewer.Knowledge.SetLover(other.ID)
+				viewer.Knowledge.LearnRole(other.ID, other.Role.GetID())
+			}
+		}
+	case EventSeerCheck:
+		result, _ := e.Data["result"].(string)
+		if seer := player(e.Actor); seer != nil {
+			seer.Knowledge.RecordCheck(e.Target, result)
+		}
+	case EventWitchSave, EventWitchPoison:
+		if witch := player(e.Actor); witch != nil {
+			g.syncPotions(witch)
+		}
+	case EventExile:
+		// 白痴翻牌后所有人都知道他的身份
+		if revealed, _ := e.Data["revealed"].(bool); revealed {
+			role, _ := e.Data["role"].(string)
+			for _, p := range g.Players {
+				p.Knowledge.Reveal(e.Target, role)
+			}
+		}
+	}
+}
+
+// 同步女巫知识中剩余的药剂
+func (g *WerewolfGame) syncPotions(p *Player) {
+	if witch, ok := p.Role.(*Witch); ok {
+		p.Knowledge.SetPotions(witch.HasAntidote, witch.HasPoison)
+	}
+}
+
+// 女巫查看当晚的刀口并记入知识，看不到或没有刀口时返回空字符串
+func (g *WerewolfGame) ShowWitchVictim(witchPlayer *Player) string {
+	witch, ok := witchPlayer.Role.(*Witch)
+	victim := g.Night.Target(ActionKill)
+	if !ok || victim == "" || !g.WitchSeesVictim(witch) {
+		return ""
+	}
+	witchPlayer.Knowledge.SeeVictim(g.DayCount, victim)
+	return victim
+}
+
+// 获取第 n 个事件之后新记录的全部事件
+func (g *WerewolfGame) EventsSince(n int) []GameEvent {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if n >= len(g.Events) {
+		return nil
+	}
+	return append([]GameEvent{}, g.Events[n:]...)
+}
+
+// 获取玩家视角可见的事件，viewer 为nil时返回上帝视角的全部事件
+func (g *WerewolfGame) EventsFor(viewer *Player) []GameEvent {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if viewer == nil {
+		return append([]GameEvent{}, g.Events...)
+	}
+	return filterEvents(g.Events, viewer.ID, viewer.IsWolf())
+}
+
+// 按板子随机分配角色
+func (g *WerewolfGame) RandomAllocate() error {
+	board, err := GetRoleBoard(g.Options.Board)
 	if err != nil {
-		return nil, fmt.Errorf("无法启动服务器: %v", err)
+		return err
+	}
+
+	roleNames, err := board.RolesFor(len(g.Players))
+	if err != nil {
+		return err
+	}
+
+	roles := []Role{}
+	for _, roleName := range roleNames {
+		role := CreateRole(roleName)
+		if witch, ok := role.(*Witch); ok {
+			witch.Game = g
+		}
+		roles = append(roles, role)
+	}
+
+	g.rng.Shuffle(len(roles), func(i, j int) {
+		roles[i], roles[j] = roles[j], roles[i]
+	})
+
+	// 分配给玩家
+	for i, player := range g.Players {
+		player.Role = roles[i]
+	}
+
+	// 记录分配情况
+	g.Log(fmt.Sprintf("=== 角色分配 (板子: %s) ===", board.Name))
+	for _, p := range g.Players {
+		g.Log(fmt.Sprintf("%s 的角色是 %s", p.Name, p.Role.GetName()))
+		event := GameEvent{
+			Type:       EventRoleAssigned,
+			Actor:      p.ID,
+			Visibility: VisibilityPlayer,
+			Viewer:     p.ID,
+			Data:       map[string]interface{}{"role": p.Role.GetID()},
+		}
+		if p.IsWolf() {
+			event.Visibility, event.Viewer = VisibilityWolves, "" // 狼人互相知道身份
+		}
+		g.Record(event)
+	}
+	return nil
+}
+
+// 添加玩家
+// 添加玩家，按加入顺序分配座位号作为ID，与已有玩家重名时在名称后追加序号
+func (g *WerewolfGame) AddPlayer(player *Player) {
+	player.game = g
+	player.ID = strconv.Itoa(len(g.Players) + 1)
+	player.Name = g.uniqueName(player.Name)
+	g.Players = append(g.Players, player)
+}
+
+// 为重名的玩家生成唯一的显示名称
+func (g *WerewolfGame) uniqueName(name string) string {
+	taken := func(candidate string) bool {
+		for _, p := range g.Players {
+			if p.Name == candidate {
+				return true
+			}
+		}
+		return false
+	}
+
+	if !taken(name) {
+		return name
+	}
+	for i := 2; ; i++ {
+		candidate := fmt.Sprintf("%s(%d)", name, i)
+		if !taken(candidate) {
+			return candidate
+		}
+	}
+}
+
+// 按ID查找玩家
+func (g *WerewolfGame) FindPlayer(id string) *Player {
+	for _, p := range g.Players {
+		if p.ID == id {
+			return p
+		}
+	}
+	return nil
+}
+
+// 获取玩家ID对应的显示名称，找不到时返回ID本身
+func (g *WerewolfGame) NameOf(id string) string {
+	if p := g.FindPlayer(id); p != nil {
+		return p.Name
+	}
+	return id
+}
+
+// 获取玩家的座位序号，不在游戏中时返回-1
+func (g *WerewolfGame) SeatOf(player *Player) int {
+	for i, p := range g.Players {
+		if p == player {
+			return i
+		}
+	}
+	return -1
+}
+
+// 玩家死亡，警长死亡时移交警徽
+func (g *WerewolfGame) KillPlayer(player *Player, cause string) {
+	player.Alive = false
+	player.DeathCause = cause
+	g.LastDeath = player
+
+	// 夜间死因不公开，只公布死于夜晚
+	publicCause := cause
+	if cause == DeathByWolf || cause == DeathByPoison {
+		publicCause = "night"
+	}
+	g.Record(GameEvent{
+		Type:       EventDeath,
+		Target:     player.ID,
+		Visibility: VisibilityPublic,
+		Data:       map[string]interface{}{"cause": publicCause},
+	})
+}
+
+// 丘比特连接两名玩家成为情侣，人狼恋时情侣与丘比特成为第三方阵营
+func (g *WerewolfGame) LinkLovers(cupidPlayer *Player, first, second *Player) bool {
+	cupid, ok := cupidPlayer.Role.(*Cupid)
+	if !ok || cupid.Linked || first == nil || second == nil || first == second || !first.Alive || !second.Alive {
+		return false
+	}
+
+	cupid.Linked = true
+	first.Lover = second
+	second.Lover = first
+	g.LoversFaction = first.IsWolf() != second.IsWolf()
+	g.Log(fmt.Sprintf("丘比特 %s 连接了情侣 %s 和 %s", cupidPlayer.Name, first.Name, second.Name))
+
+	// 只有丘比特和两名情侣知道连接结果，丘比特连接自己时只记录一次
+	viewers := []*Player{cupidPlayer}
+	for _, lover := range []*Player{first, second} {
+		if lover != cupidPlayer {
+			viewers = append(viewers, lover)
+		}
+	}
+	for _, viewer := range viewers {
+		g.Record(GameEvent{
+			Type:       EventLoversLinked,
+			Actor:      cupidPlayer.ID,
+			Visibility: VisibilityPlayer,
+			Viewer:     viewer.ID,
+			Data:       map[string]interface{}{"lovers": []string{first.ID, second.ID}},
+		})
+	}
+	if g.LoversFaction {
+		g.Log("情侣为人狼恋，与丘比特组成第三方阵营")
+	}
+	return true
+}
+
+// 获取玩家所属阵营
+func (g *WerewolfGame) FactionOf(player *Player) string {
+	if g.LoversFaction && (player.Lover != nil || player.IsCupid()) {
+		return FactionLovers
+	}
+	if player.IsWolf() {
+		return FactionWolf
+	}
+	return FactionGood
+}
+
+// 在候选人中统计警长选票，选出警长时返回nil，否则返回得票最高的平票候选人
+func (g *WerewolfGame) ElectSheriff(candidates []*Player) []*Player {
+	maxVotes := float64(0)
+	for _, p := range candidates {
+		if p.Votes > maxVotes {
+			maxVotes = p.Votes
+		}
+	}
+
+	leaders := []*Player{}
+	for _, p := range candidates {
+		if p.Votes == maxVotes {
+			leaders = append(leaders, p)
+		}
+	}
+	g.resetVotes()
+
+	if len(leaders) == 1 {
+		g.SetSheriff(leaders[0])
+		return nil
+	}
+	return leaders
+}
+
+// 任命警长
+func (g *WerewolfGame) SetSheriff(p *Player) {
+	g.Sheriff = p
+	p.Sheriff = true
+	g.Log(fmt.Sprintf("\n%s 当选警长！", p.Name))
+	g.Record(GameEvent{Type: EventSheriffElected, Target: p.ID, Visibility: VisibilityPublic})
+}
+
+// 重置投票
+func (g *WerewolfGame) resetVotes() {
+	for _, p := range g.Players {
+		p.Votes = 0
+	}
+}
+
+// 投票出局，返回被放逐的玩家，平票时返回nil
+func (g *WerewolfGame) Vote() *Player {
+	alivePlayers := []*Player{}
+	for _, p := range g.Players {
+		if p.Alive {
+			alivePlayers = append(alivePlayers, p)
+		}
+	}
+
+	if len(alivePlayers) == 0 {
+		return nil
+	}
+
+	candidates := g.topVoted()
+
+	tally := map[string]interface{}{}
+	for _, p := range g.Players {
+		if p.Votes > 0 {
+			tally[p.ID] = p.Votes
+		}
+	}
+	event := GameEvent{Type: EventExile, Visibility: VisibilityPublic, Data: map[string]interface{}{"votes": tally}}
+
+	var killed *Player
+	if len(candidates) == 1 && candidates[0].IsIdiot() && !candidates[0].Revealed {
+		// 白痴第一次被放逐时翻牌，留在场上但失去投票权
+		idiot := candidates[0]
+		idiot.Revealed = true
+		idiot.CanVote = false
+		g.Log(fmt.Sprintf("\n%s 被投票出局，翻牌为白痴，免于出局但失去投票权", idiot.Name))
+		event.Target = idiot.ID
+		event.Data["revealed"] = true
+		event.Data["role"] = idiot.Role.GetID()
+		g.Record(event)
+	} else if len(candidates) == 1 {
+		killed = candidates[0]
+		g.Log(fmt.Sprintf("\n%s 被投票出局", killed.Name))
+		event.Target = killed.ID
+		g.Record(event)
+		g.KillPlayer(killed, DeathByVote)
+	} else {
+		g.Log("平票，无人出局")
+		event.Data["tied"] = playerIDs(candidates)
+		g.Record(event)
+	}
+
+	g.resetVotes()
+	return killed
+}
+
+// 获取当前得票最高的存活玩家
+func (g *WerewolfGame) topVoted() []*Player {
+	maxVotes := float64(0)
+	for _, p := range g.Players {
+		if p.Alive && p.Votes > maxVotes {
+			maxVotes = p.Votes
+		}
+	}
+
+	candidates := []*Player{}
+	for _, p := range g.Players {
+		if p.Alive && p.Votes == maxVotes {
+			candidates = append(candidates, p)
+		}
+	}
+	return candidates
+}
+
+// 移交警徽，heir 为nil时撕毁警徽
+func (g *WerewolfGame) TransferSheriff(heir *Player) {
+	oldName, oldID := "无", ""
+	if g.Sheriff != nil {
+		oldName, oldID = g.Sheriff.Name, g.Sheriff.ID
+		g.Sheriff.Sheriff = false
+		g.Sheriff = nil
+	}
+
+	if heir == nil || !heir.Alive {
+		g.Log(fmt.Sprintf("警长 %s 撕毁了警徽，本局不再有警长", oldName))
+		g.Record(GameEvent{Type: EventBadgeTransfer, Actor: oldID, Visibility: VisibilityPublic, Data: map[string]interface{}{"torn": true}})
+		return
+	}
+	g.Sheriff = heir
+	heir.Sheriff = true
+	g.Log(fmt.Sprintf("警长 %s 将警徽交给了 %s，%s 成为新警长！", oldName, heir.Name, heir.Name))
+	g.Record(GameEvent{Type: EventBadgeTransfer, Actor: oldID, Target: heir.ID, Visibility: VisibilityPublic})
+}
+
+// 检查游戏是否结束，结束时记录获胜阵营
+func (g *WerewolfGame) CheckGameEnd() bool {
+	aliveWerewolves := 0
+	aliveVillagers := 0
+	aliveLovers := 0
+	loversAlive := false
+	totalGods, aliveGods := 0, 0
+	totalPlain, alivePlain := 0, 0
+
+	for _, p := range g.Players {
+		if p.IsGod() {
+			totalGods++
+		} else if !p.IsWolf() {
+			totalPlain++
+		}
+
+		if p.Alive {
+			if g.FactionOf(p) == FactionLovers {
+				aliveLovers++
+				loversAlive = loversAlive || p.Lover != nil
+			}
+			if p.IsWolf() {
+				aliveWerewolves++
+			} else {
+				aliveVillagers++
+				if p.IsGod() {
+					aliveGods++
+				} else {
+					alivePlain++
+				}
+			}
+		}
+	}
+
+	g.Log(fmt.Sprintf("当前存活情况: %d 狼人, %d 好人 (%d 神职, %d 平民)",
+		aliveWerewolves, aliveVillagers, aliveGods, alivePlain))
+
+	// 第三方情侣存活时，只有情侣阵营成为最后的幸存者才会结束游戏
+	if g.LoversFaction && loversAlive {
+		g.Log(fmt.Sprintf("第三方阵营存活 %d 人", aliveLovers))
+		if aliveLovers == aliveWerewolves+aliveVillagers {
+			g.Log("\n情侣阵营胜利！")
+			g.Winner = FactionLovers
+			return true
+		}
+		return false
+	}
+
+	wolvesWin := false
+	switch g.Options.WinCondition {
+	case WinBySide:
+		// 板子中没有的身份不参与屠边判定
+		wolvesWin = (totalGods > 0 && aliveGods == 0) || (totalPlain > 0 && alivePlain == 0)
+	case WinByCity:
+		wolvesWin = aliveVillagers == 0
+	default:
+		wolvesWin = aliveWerewolves >= aliveVillagers
+	}
+
+	if aliveWerewolves == 0 {
+		g.Log("\n好人阵营胜利！")
+		g.Winner = FactionGood
+		return true
+	} else if wolvesWin {
+		g.Log("\n狼人阵营胜利！")
+		g.Winner = FactionWolf
+		return true
+	}
+	return false
+}
+
+// 获取获胜阵营的所有玩家
+func (g *WerewolfGame) WinningPlayers() []*Player {
+	winners := []*Player{}
+	for _, p := range g.Players {
+		if g.Winner != "" && g.FactionOf(p) == g.Winner {
+			winners = append(winners, p)
+		}
+	}
+	return winners
+}
+
+// 白天行动，公布夜间结算出的死亡玩家并返回
+func (g *WerewolfGame) DayActions() []*Player {
+	g.Log(fmt.Sprintf("第 %d 天白天", g.DayCount))
+	deaths := g.NightDeaths
+
+	// 宣布夜晚死亡的玩家
+	for _, p := range deaths {
+		if p.DeathCause == DeathByPoison {
+			g.Log(fmt.Sprintf("%s 被毒死了", p.Name))
+		} else {
+			g.Log(fmt.Sprintf("\n%s 昨晚被狼人杀死了", p.Name))
+		}
+	}
+
+	// 所有死亡玩家确定后再移交警徽
+	for _, p := range deaths {
+		g.KillPlayer(p, p.DeathCause)
+	}
+
+	g.NightDeaths = nil
+	g.DayCount++
+	return deaths
+}
+
+// 开始新的夜晚
+func (g *WerewolfGame) BeginNight() {
+	g.Log(fmt.Sprintf("第 %d 天黑夜", g.DayCount))
+	g.Night.Reset()
+}
+
+// 校验并收集一名玩家的夜间行动，行动在天亮结算前不会生效，无效时返回false
+func (g *WerewolfGame) SubmitNightAction(actor *Player, kind string, targets ...string) bool {
+	if !actor.Alive || len(targets) == 0 {
+		return false
+	}
+
+	valid := false
+	target := aliveTarget(g.Players, targets[0])
+	switch kind {
+	case ActionLink:
+		cupid, ok := actor.Role.(*Cupid)
+		valid = ok && !cupid.Linked && len(targets) == 2 && target != nil && targets[0] != targets[1] &&
+			aliveTarget(g.Players, targets[1]) != nil && !g.Night.Submitted(actor, kind)
+	case ActionGuard:
+		guard, ok := actor.Role.(*Guard)
+		valid = ok && (targets[0] == "" || target != nil && targets[0] != guard.LastProtected) && !g.Night.Submitted(actor, kind)
+	case ActionWolfVote:
+		// 狼人可以改票，统计时以最后一次投票为准
+		valid = actor.IsWolf() && target != nil && !target.IsWolf()
+		if valid {
+			g.Log(fmt.Sprintf("狼人 %s 选择击杀 %s", actor.Name, target.Name))
+			g.Record(GameEvent{Type: EventWolfVote, Actor: actor.ID, Target: target.ID, Visibility: VisibilityWolves})
+		}
+	case ActionSave:
+		valid = targets[0] == g.Night.Target(ActionKill) && g.CanWitchSave(actor) && !g.Night.Submitted(actor, kind)
+	case ActionPoison:
+		// 默认每晚只能使用一瓶药
+		witch, ok := actor.Role.(*Witch)
+		valid = ok && witch.HasPoison && target != nil && target != actor && !g.Night.Submitted(actor, kind) &&
+			(g.Options.Witch.BothPotions || !g.Night.Submitted(actor, ActionSave))
+	case ActionCheck:
+		valid = actor.IsSeer() && target != nil && target != actor && !g.Night.Submitted(actor, kind)
+	}
+
+	if !valid {
+		return false
+	}
+	g.Night.Add(NightAction{Type: kind, Actor: actor, Targets: targets})
+
+	// 连接情侣和查验不与其他行动冲突，立即生效
+	switch kind {
+	case ActionLink:
+		g.LinkLovers(actor, target, g.FindPlayer(targets[1]))
+	case ActionCheck:
+		result := CheckResult(target)
+		g.Log(fmt.Sprintf("预言家 %s 查验 %s 的身份是 %s", actor.Name, target.Name, Text("check."+result).Render(DefaultLocale)))
+		g.RecordSeerCheck(actor, target, result)
+	}
+	return true
+}
+
+// 预言家查验玩家得到的结果
+func CheckResult(target *Player) string {
+	if target.IsWolf() {
+		return FactionWolf
+	}
+	return FactionGood
+}
+
+// 当晚每名狼人的当前选择，狼人ID -> 目标ID，以最后一次投票为准
+func (g *WerewolfGame) WolfVotes() map[string]string {
+	latest := map[string]string{}
+	for _, a := range g.Night.Of(ActionWolfVote) {
+		if a.Actor.Alive {
+			latest[a.Actor.ID] = a.Target()
+		}
+	}
+	return latest
+}
+
+// 判断存活狼人是否都已投票并且选择了同一目标
+func (g *WerewolfGame) WolvesAgree() bool {
+	votes := g.WolfVotes()
+	target := ""
+	for _, p := range g.Players {
+		if p.Alive && p.IsWolf() {
+			vote, ok := votes[p.ID]
+			if !ok || (target != "" && vote != target) {
+				return false
+			}
+			target = vote
+		}
+	}
+	return target != ""
+}
+
+// 统计狼人投票得出狼队的击杀目标，每名狼人以最后一次投票为准，平票时随机选择
+func (g *WerewolfGame) TallyWolfVotes() string {
+	votes := make(map[string]int)
+	for _, targetID := range g.WolfVotes() {
+		votes[targetID]++
+	}
+	g.Log(fmt.Sprintf("狼人投票结果: %v", votes))
+
+	maxVotes := 0
+	for _, count := range votes {
+		if count > maxVotes {
+			maxVotes = count
+		}
+	}
+
+	candidates := []string{}
+	for id, count := range votes {
+		if count == maxVotes {
+			candidates = append(candidates, id)
+		}
+	}
+	sort.Strings(candidates) // 固定顺序，保证同一种子结果一致
+
+	if len(candidates) == 0 {
+		return ""
+	}
+	kill := candidates[g.rng.Intn(len(candidates))]
+	g.Night.Add(NightAction{Type: ActionKill, Targets: []string{kill}})
+	g.Log(fmt.Sprintf("今晚狼人选择了击杀 %s", g.NameOf(kill)))
+	g.Record(GameEvent{Type: EventWolfKill, Target: kill, Visibility: VisibilityWolves})
+	return kill
+}
+
+// 守卫守护目标，目标为空表示空守，目标已在收集行动时校验
+func (g *WerewolfGame) GuardProtect(guardPlayer *Player, targetID string) bool {
+	guard, ok := guardPlayer.Role.(*Guard)
+	if !ok {
+		return false
+	}
+
+	guard.LastProtected = targetID
+	if targetID == "" {
+		g.Log(fmt.Sprintf("守卫 %s 今晚空守", guardPlayer.Name))
+		g.Record(GameEvent{Type: EventGuardProtect, Actor: guardPlayer.ID, Visibility: VisibilityPlayer, Viewer: guardPlayer.ID})
+		return true
+	}
+
+	g.Log(fmt.Sprintf("守卫 %s 守护了 %s", guardPlayer.Name, g.NameOf(targetID)))
+	g.Record(GameEvent{Type: EventGuardProtect, Actor: guardPlayer.ID, Target: targetID, Visibility: VisibilityPlayer, Viewer: guardPlayer.ID})
+	return true
+}
+
+// 按 NightPriority 的顺序统一结算当晚收集的行动。
+// 刀口被守卫守护或被女巫救治即可免死，同守同救依然死亡；毒药不受守卫影响，被刀又被毒的玩家按毒死处理
+func (g *WerewolfGame) ResolveNight() *NightOutcome {
+	outcome := &NightOutcome{Deaths: []*Player{}}
+	kills := []string{}
+	poisons := []string{}
+	guarded := map[string]bool{}
+	saved := map[string]bool{}
+
+	for _, kind := range NightPriority {
+		for _, a := range g.Night.Of(kind) {
+			switch kind {
+			case ActionGuard:
+				g.GuardProtect(a.Actor, a.Target())
+				guarded[a.Target()] = a.Target() != ""
+			case ActionKill:
+				kills = append(kills, a.Target())
+			case ActionSave:
+				a.Actor.Role.(*Witch).HasAntidote = false
+				saved[a.Target()] = true
+				g.RecordWitchSave(a.Actor, g.FindPlayer(a.Target()))
+			case ActionPoison:
+				a.Actor.Role.(*Witch).HasPoison = false
+				poisons = append(poisons, a.Target())
+				g.Log(fmt.Sprintf("女巫 %s 对 %s 使用了毒药", a.Actor.Name, g.NameOf(a.Target())))
+				g.RecordWitchPoison(a.Actor, g.FindPlayer(a.Target()))
+			}
+		}
+	}
+
+	// 死亡的玩家立即失去行动能力，天亮公布时才正式出局
+	die := func(p *Player, cause string) {
+		p.Alive = false
+		p.DeathCause = cause
+		p.Poisoned = p.Poisoned || cause == DeathByPoison
+		if !containsPlayer(outcome.Deaths, p) {
+			outcome.Deaths = append(outcome.Deaths, p)
+		}
+	}
+	for _, id := range kills {
+		target := g.FindPlayer(id)
+		switch {
+		case guarded[id] && saved[id]:
+			g.Log(fmt.Sprintf("%s 同时被守卫守护和女巫救治，同守同救依然死亡", target.Name))
+		case guarded[id]:
+			g.Log(fmt.Sprintf("%s 被守卫守护，免于狼人击杀", target.Name))
+			continue
+		case saved[id]:
+			g.Log(fmt.Sprintf("%s 被女巫救活了", target.Name))
+			continue
+		default:
+			g.Log(fmt.Sprintf("狼人选择了击杀 %s", target.Name))
+		}
+		die(target, DeathByWolf)
+	}
+	for _, id := range poisons {
+		die(g.FindPlayer(id), DeathByPoison)
+	}
+
+	g.NightDeaths = outcome.Deaths
+	g.Night.Reset()
+	return outcome
+}
+
+// ============ 游戏结果定义 ============
+
+// GameResult 存储游戏结果
+type GameResult struct {
+	GameID         int
+	Port           int
+	Duration       time.Duration
+	WinningFaction string
+	WinCondition   string
+	Seed           int64
+	Winners        []string
+	Players        []PlayerInfo
+	Logs           []string
+	Events         []GameEvent
+	Error          error
+}
+
+// 获取指定玩家视角可见的事件，playerID 为空时返回全部事件
+func (r *GameResult) EventsFor(playerID string) []GameEvent {
+	if playerID == "" {
+		return r.Events
+	}
+	for _, p := range r.Players {
+		if p.ID == playerID {
+			return filterEvents(r.Events, p.ID, p.IsWolf)
+		}
+	}
+	return filterEvents(r.Events, "", false)
+}
+
+type PlayerInfo struct {
+	ID        string
+	Name      string
+	Role      string
+	Alive     bool
+	IsWolf    bool
+	Faction   string
+	Lover     string
+	Strategy  string // AI玩家使用的策略，真人玩家为空
+	IsBot     bool   // 座位由外部机器人控制
+	TakenOver bool   // 外部机器人中途被内置AI接管
+}
+
+// ============ 游戏实例定义 ============
+
+// GameInstance 表示一个游戏实例
+type GameInstance struct {
+	GameID    int
+	Port      int
+	Server    *GameServer
+	IsRunning bool
+	StartTime time.Time
+	Result    *GameResult
+	mu        sync.Mutex
+}
+
+// ============ 游戏服务器定义 ============
+
+// 客户端连接
+type ClientConnection struct {
+	conn    net.Conn
+	player  *Player
+	decoder *json.Decoder
+	inbox   chan map[string]interface{} // 等待游戏流程读取的回复消息
+	pending map[string]interface{}      // 尚未得到有效回复的提示，用于校验回复
+	stalled bool                        // 外部机器人没有响应，等待在阶段之间由内置AI接管
+}
+
+// 客户端使用的语言，玩家加入前使用默认语言
+func (c *ClientConnection) locale() string {
+	if c.player == nil {
+		return DefaultLocale
+	}
+	return c.player.Locale
+}
+
+// 观战者，只接收公开消息、事件和状态快照，不会收到任何行动提示
+type Spectator struct {
+	conn   net.Conn
+	Name   string
+	God    bool // 上帝视角，可以看到所有身份和全部事件
+	locale string
+}
+
+// 观战视角名称
+func (sp *Spectator) View() string {
+	if sp.God {
+		return VisibilityGod
+	}
+	return VisibilityPublic
+}
+
+// 判断观战者能否看到事件，公开视角只能看到公开事件
+func (sp *Spectator) CanSee(e GameEvent) bool {
+	return sp.God || e.Visibility == VisibilityPublic
+}
+
+// 狼人自爆请求
+type explodeRequest struct {
+	player *Player
+	target string // 白狼王带走的目标
+}
+
+// 游戏服务器
+type GameServer struct {
+	game           *WerewolfGame
+	clients        []*ClientConnection
+	voteLock       sync.Mutex
+	numRealPlayers int
+	numAIPlayers   int
+	port           int
+	host           string
+	listener       net.Listener
+	running        atomic.Bool // 游戏是否正在运行，会在其他 goroutine 中读取
+	result         *GameResult
+	explodes       chan explodeRequest    // 玩家主动发起的自爆请求
+	interrupt      chan struct{}          // 白天被自爆打断时关闭
+	stateLock      sync.Mutex             // 保护 interrupt、speaker、wolfChannel 和客户端的 stalled
+	speaker        *Player                // 当前发言的玩家
+	wolfChannel    bool                   // 狼人夜间频道是否开放
+	stopWatching   func() *explodeRequest // 停止监听本轮白天的自爆
+	phases         *PhaseMachine
+	lastWords      []*Player // 等待发表遗言的玩家
+	pkCandidates   []*Player // 平票进入PK的玩家
+	spectators     []*Spectator
+	spectatorLock  sync.Mutex // 保护 spectators 和 streamed
+	streamed       int        // 已推送给观战者的事件数量
+}
+
+// 创建新服务器
+func NewGameServer() *GameServer {
+	s := &GameServer{
+		game:     NewWerewolfGame(),
+		clients:  []*ClientConnection{},
+		voteLock: sync.Mutex{},
+		explodes: make(chan explodeRequest, 8),
+		phases:   NewPhaseMachine(PhaseNightfall),
+	}
+	s.registerPhases()
+	return s
+}
+
+// 注册游戏阶段及其允许的转换
+func (s *GameServer) registerPhases() {
+	s.phases.Register(&Phase{Name: PhaseNightfall, Next: []string{PhaseNightCupid}, Run: s.phaseNightfall})
+	s.phases.Register(&Phase{Name: PhaseNightCupid, Next: []string{PhaseNightGuard}, Run: s.phaseNightCupid})
+	s.phases.Register(&Phase{Name: PhaseNightGuard, Next: []string{PhaseNightWolf}, Run: s.phaseNightGuard})
+	s.phases.Register(&Phase{Name: PhaseNightWolf, Next: []string{PhaseNightWitch}, Run: s.phaseNightWolf})
+	s.phases.Register(&Phase{Name: PhaseNightWitch, Next: []string{PhaseNightSeer}, Run: s.phaseNightWitch})
+	s.phases.Register(&Phase{Name: PhaseNightSeer, Next: []string{PhaseDawn}, Run: s.phaseNightSeer})
+	s.phases.Register(&Phase{Name: PhaseDawn, Next: []string{PhaseAnnounce, PhaseSheriff}, Run: s.phaseDawn})
+	s.phases.Register(&Phase{Name: PhaseSheriff, Next: []string{PhaseAnnounce}, Run: s.phaseSheriff})
+	s.phases.Register(&Phase{Name: PhaseAnnounce, Next: []string{PhaseDiscussion, PhaseLastWords, PhaseGameOver}, Run: s.phaseAnnounce})
+	s.phases.Register(&Phase{Name: PhaseDiscussion, Next: []string{PhaseVote, PhaseNightfall, PhaseGameOver}, Run: s.phaseDiscussion})
+	s.phases.Register(&Phase{Name: PhaseVote, Next: []string{PhaseNightfall, PhaseLastWords, PhasePK, PhaseGameOver}, Run: s.phaseVote})
+	s.phases.Register(&Phase{Name: PhasePK, Next: []string{PhaseNightfall, PhaseLastWords, PhaseGameOver}, Run: s.phasePK})
+	s.phases.Register(&Phase{Name: PhaseLastWords, Next: []string{PhaseNightfall, PhaseDiscussion}, Run: s.phaseLastWords})
+}
+
+// 启动服务器 - 现在接受参数并返回结果
+func (s *GameServer) Start(host string, port int, numRealPlayers, numAIPlayers int, opts GameOptions) (*GameResult, error) {
+	s.host = host
+	s.port = port
+	s.numRealPlayers = numRealPlayers
+	s.numAIPlayers = numAIPlayers
+	if opts.WinCondition == "" {
+		opts.WinCondition = WinByParity
+	}
+	if opts.SpeechSeconds <= 0 {
+		opts.SpeechSeconds = DefaultSpeechSeconds
+	}
+	if opts.WolfSeconds <= 0 {
+		opts.WolfSeconds = DefaultWolfSeconds
+	}
+	if opts.LastWords == "" {
+		opts.LastWords = LastWordsFirstNight
+	}
+	if opts.BadgeDefault == "" {
+		opts.BadgeDefault = BadgeDefaultTear
+	}
+	if opts.Witch.SelfSave == "" {
+		opts.Witch.SelfSave = SelfSaveFirstNight
+	}
+	if opts.AIStrategy == "" {
+		opts.AIStrategy = DefaultStrategy
+	}
+	s.game.Options = opts
+	s.game.SetSeed(opts.Seed)
+
+	// 创建结果对象
+	result := &GameResult{
+		Port:    port,
+		Players: []PlayerInfo{},
+	}
+	s.result = result
+
+	// 设置开始时间
+	startTime := time.Now()
+
+	// 创建监听器
+	addr := fmt.Sprintf("%s:%d", host, port)
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("无法启动服务器: %v", err)
+	}
+	s.listener = listener
+	defer listener.Close()
+
+	s.game.Log(fmt.Sprintf("服务器启动在 %s, 等待玩家连接...", addr))
+	s.game.Log(fmt.Sprintf("随机种子: %d", s.game.Options.Seed))
+
+	// 设置监听超时，避免无限等待
+	listener.(*net.TCPListener).SetDeadline(time.Now().Add(30 * time.Second))
+
+	// 接受玩家连接，观战者也连接同一端口，但不占用玩家座位
+	joins := []map[string]interface{}{}
+	for len(s.clients) < numRealPlayers {
+		conn, err := listener.Accept()
+		if err != nil {
+			// 如果是因为超时导致的错误，就继续下一步
+			if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+				s.game.Log(fmt.Sprintf("等待玩家连接超时，将自动添加 AI 替代"))
+				break
+			}
+			return nil, fmt.Errorf("接受连接失败: %v", err)
+		}
+
+		client := &ClientConnection{
+			conn:    conn,
+			player:  nil,
+			decoder: json.NewDecoder(conn),
+			inbox:   make(chan map[string]interface{}, 16),
+		}
+
+		// 接收加入消息，设置读取超时
+		conn.SetReadDeadline(time.Now().Add(10 * time.Second))
+		var message map[string]interface{}
+		if err := client.decoder.Decode(&message); err != nil {
+			s.game.Log(fmt.Sprintf("接收玩家名称失败，使用默认名称: %v", err))
+			message = map[string]interface{}{
+				"name": fmt.Sprintf("Player%d", len(s.clients)+1),
+			}
+		}
+		if spectate, _ := message["spectate"].(bool); spectate {
+			s.AddSpectator(conn, client.decoder, message)
+			continue
+		}
+
+		s.game.Log(fmt.Sprintf("玩家%d已连接: %v", len(s.clients)+1, conn.RemoteAddr()))
+		s.clients = append(s.clients, client)
+		joins = append(joins, message)
+	}
+	go s.acceptSpectators()
+
+	// 按加入消息创建玩家
+	players := []*Player{}
+	for i, client := range s.clients {
+		message := joins[i]
+		name, ok := message["name"].(string)
+		if !ok {
+			name = fmt.Sprintf("Player%d", i+1)
+		}
+
+		player := NewPlayer(name, false)
+		if locale, ok := message["locale"].(string); ok {
+			player.Locale = ParseLocale(locale)
+		}
+		if bot, _ := message["bot"].(bool); bot {
+			// 外部机器人与真人使用相同的协议，超时后由内置AI接管
+			player.IsBot = true
+			player.Strategy = NewAIStrategy(opts.AIStrategy)
+		}
+		client.player = player
+		players = append(players, player)
+		s.game.AddPlayer(player)
+	}
+
+	// 发送玩家语言下角色、阵营和查验结果的显示名称
+	for i, client := range s.clients {
+		s.SendMessage(catalogFor(client.locale()), i)
+	}
+
+	// 发送等待确认消息
+	s.BroadcastMessage(map[string]interface{}{
+		"type":    "wait_confirm",
+		"players": playerNames(players),
+		"seats":   s.seats(),
+	})
+
+	// 接收确认
+	confirmations := []bool{}
+	for _, client := range s.clients {
+		client.conn.SetReadDeadline(time.Now().Add(10 * time.Second))
+		var message map[string]interface{}
+		if err := client.decoder.Decode(&message); err != nil {
+			s.game.Log(fmt.Sprintf("接收确认失败: %v", err))
+			confirmations = append(confirmations, false)
+			continue
+		}
+		client.conn.SetReadDeadline(time.Time{}) // 重置超时
+
+		confirm, ok := message["confirm"].(bool)
+		if !ok {
+			confirmations = append(confirmations, false)
+			continue
+		}
+
+		confirmations = append(confirmations, confirm)
+	}
+
+	// 开始持续读取客户端消息
+	for _, client := range s.clients {
+		client.conn.SetReadDeadline(time.Time{})
+		go s.readLoop(client)
+	}
+
+	// 检查所有玩家是否确认
+	allConfirmed := true
+	for _, confirm := range confirmations {
+		if !confirm {
+			allConfirmed = false
+			break
+		}
+	}
+
+	// 添加AI玩家
+	aiNames := []string{"Stephanie", "Wendy", "Elmy", "Sham", "Jeffry", "Kelly", "Tony", "Alice", "Bob", "Charlie"}
+
+	// 计算需要添加的AI玩家数量，未连接的真人座位也由AI补足，保证总人数与板子一致
+	if !allConfirmed {
+		s.game.Log("有玩家未确认，继续使用AI补足座位")
+	}
+	aiPlayersToAdd := numRealPlayers + numAIPlayers - len(s.game.Players)
+
+	for i := 0; i < aiPlayersToAdd; i++ {
+		name := fmt.Sprintf("AI%d", i+1)
+		if i < len(aiNames) {
+			name = aiNames[i]
+		}
+		player := NewPlayer(name, true)
+		strategy := opts.AIStrategy
+		if i < len(opts.AIStrategies) && opts.AIStrategies[i] != "" {
+			strategy = opts.AIStrategies[i]
+		}
+		player.Strategy = NewAIStrategy(strategy)
+		s.game.AddPlayer(player)
+	}
+
+	// 分配角色，观战者从此开始收到事件推送
+	stopStreaming := s.streamEvents()
+	if err := s.game.RandomAllocate(); err != nil {
+		stopStreaming()
+		s.Stop()
+		return nil, fmt.Errorf("分配角色失败: %v", err)
+	}
+	s.running.Store(true)
+	s.SendGameStatus()
+
+	// 运行游戏
+	s.RunGameLoop()
+	stopStreaming()
+	s.closeSpectators()
+
+	// 准备结果
+	result.Duration = time.Since(startTime)
+	result.WinningFaction = s.game.Winner
+	result.WinCondition = s.game.Options.WinCondition
+	result.Seed = s.game.Options.Seed
+	result.Winners = playerNames(s.game.WinningPlayers())
+
+	for _, p := range s.game.Players {
+		lover := ""
+		if p.Lover != nil {
+			lover = p.Lover.Name
+		}
+		strategy := ""
+		if p.IsAI || p.IsBot {
+			strategy = p.AI().Name()
+		}
+		result.Players = append(result.Players, PlayerInfo{
+			ID:        p.ID,
+			Name:      p.Name,
+			Role:      p.Role.GetID(),
+			Alive:     p.Alive,
+			IsWolf:    p.IsWolf(),
+			Faction:   s.game.FactionOf(p),
+			Lover:     lover,
+			Strategy:  strategy,
+			IsBot:     p.IsBot,
+			TakenOver: p.TakenOver,
+		})
+	}
+
+	result.Logs = s.game.Logs
+	result.Events = s.game.EventsFor(nil)
+
+	return result, nil
+}
+
+// 停止服务器
+func (s *GameServer) Stop() {
+	if s.listener != nil {
+		s.listener.Close()
+	}
+
+	for _, client := range s.clients {
+		if client.conn != nil {
+			client.conn.Close()
+		}
+	}
+	s.closeSpectators()
+
+	s.running.Store(false)
+}
+
+// 广播消息给所有客户端和观战者，文本按每个人的语言渲染
+func (s *GameServer) BroadcastMessage(message map[string]interface{}) {
+	for _, client := range s.clients {
+		err := json.NewEncoder(client.conn).Encode(localize(message, client.locale()))
+		if err != nil {
+			s.game.Log(fmt.Sprintf("发送消息失败: %v", err))
+		}
+	}
+	s.broadcastSpectators(message)
+}
+
+// 发送消息给指定客户端，提示会被记下用于校验回复，外部机器人收到的提示还会附带该玩家视角的局面；
+// 提示只由游戏主流程发出，读取连接的 goroutine 发送的消息不会生成局面，避免与主流程并发读写游戏状态
+func (s *GameServer) SendMessage(message map[string]interface{}, index int) {
+	if index >= 0 && index < len(s.clients) {
+		if msgType, _ := message["type"].(string); promptTypes[msgType] {
+			s.clients[index].pending = message
+			if player := s.clients[index].player; player != nil && player.IsBot && player.Role != nil {
+				message = s.botMessage(s.clients[index], message)
+			}
+		}
+		err := json.NewEncoder(s.clients[index].conn).Encode(localize(message, s.clients[index].locale()))
+		if err != nil {
+			s.game.Log(fmt.Sprintf("发送消息失败: %v", err))
+		}
+	} else {
+		s.game.Log(fmt.Sprintf("客户端索引越界: %d，客户端数量: %d", index, len(s.clients)))
+	}
+}
+
+// 接收消息，超时、连接关闭或白天被自爆打断时返回nil；
+// 外部机器人超时或断开时只做标记，由游戏主流程在收集结束后交给内置AI接管，并由AI补上这次行动
+func (s *GameServer) ReceiveMessage(index int) map[string]interface{} {
+	if index < 0 || index >= len(s.clients) {
+		return nil
+	}
+	client := s.clients[index]
+
+	// 无效的回复会被拒绝，玩家可以在截止时间前重新回复
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		message, timedOut := s.receiveWithin(index, time.Until(deadline))
+		if timedOut {
+			s.game.Log(fmt.Sprintf("接收消息超时: 客户端 %d", index))
+		}
+		if message == nil {
+			if player := client.player; !isClosed(s.promptInterrupt(client)) && player != nil && player.IsBot && !player.IsAI {
+				s.markStalled(client)
+			}
+			client.pending = nil
+			return nil
+		}
+
+		reason := validateResponse(client.pending, message)
+		if reason == nil {
+			client.pending = nil
+			return message
+		}
+		s.rejectResponse(index, reason, deadline)
+	}
+}
+
+// 拒绝无效的回复并告知原因，玩家可以在截止时间前重新回复
+func (s *GameServer) rejectResponse(index int, reason *LocalText, deadline time.Time) {
+	client := s.clients[index]
+	name := fmt.Sprintf("客户端 %d", index)
+	if client.player != nil {
+		name = client.player.Name
+	}
+	s.game.Log(fmt.Sprintf("%s 的行动被拒绝: %s", name, reason.Render(DefaultLocale)))
+	rejection := map[string]interface{}{
+		"type":         "action_rejected",
+		"prompt":       client.pending["type"],
+		"reason":       *reason,
+		"seconds_left": int(time.Until(deadline) / time.Second),
+	}
+	if action, ok := client.pending["action"]; ok {
+		rejection["action"] = action
+	}
+	s.SendMessage(rejection, index)
+}
+
+// 需要玩家回复的提示类型
+var promptTypes = map[string]bool{
+	"sheriff_signup":   true,
+	"sheriff_withdraw": true,
+	"sheriff_election": true,
+	"day_vote":         true,
+	"pk_vote":          true,
+	"night_action":     true,
+	"hunter_shoot":     true,
+	"badge_transfer":   true,
+	"speech_direction": true,
+	"wolf_king_target": true,
+}
+
+// 按发出的提示校验玩家的回复，返回拒绝原因，有效时返回nil
+func validateResponse(prompt, response map[string]interface{}) *LocalText {
+	if prompt == nil {
+		return nil
+	}
+	if response == nil {
+		return reject("reject.empty")
+	}
+	candidates, _ := prompt["candidates"].([]string)
+
+	switch prompt["type"] {
+	case "sheriff_signup":
+		return checkFlag(response, "run")
+	case "sheriff_withdraw":
+		return checkFlag(response, "withdraw")
+	case "sheriff_election", "day_vote", "pk_vote":
+		return checkChoice(response, "vote", candidates)
+	case "wolf_king_target":
+		return checkChoice(response, "target", candidates)
+	case "speech_direction":
+		options, _ := prompt["options"].([]string)
+		return checkChoice(response, "direction", options)
+	case "hunter_shoot":
+		if reason := checkFlag(response, "skip"); reason != nil {
+			return reason
+		}
+		return checkChoice(response, "target", candidates)
+	case "badge_transfer":
+		if reason := checkFlag(response, "tear"); reason != nil {
+			return reason
+		}
+		return checkChoice(response, "target", candidates)
+	case "night_action":
+		switch prompt["action"] {
+		case "werewolf", "guard", "seer":
+			return checkChoice(response, "target", candidates)
+		case "cupid":
+			return checkLovers(response, candidates)
+		case "witch":
+			return checkWitch(prompt, response)
+		}
+	}
+	return nil
+}
+
+// 生成拒绝原因
+func reject(key string, args ...interface{}) *LocalText {
+	reason := Text(key, args...)
+	return &reason
+}
+
+// 校验回复中的布尔字段，字段缺失时视为 false
+func checkFlag(response map[string]interface{}, key string) *LocalText {
+	if value, present := response[key]; present {
+		if _, ok := value.(bool); !ok {
+			return reject("reject.not_bool", key)
+		}
+	}
+	return nil
+}
+
+// 校验回复中选择的玩家是否在候选人中，字段缺失或为空表示放弃
+func checkChoice(response map[string]interface{}, key string, candidates []string) *LocalText {
+	value, present := response[key]
+	if !present || value == "" {
+		return nil
+	}
+	id, ok := value.(string)
+	if !ok {
+		return reject("reject.not_string", key)
+	}
+	if !containsString(candidates, id) {
+		return reject("reject.invalid_choice", id)
+	}
+	return nil
+}
+
+// 校验丘比特连接的两名玩家
+func checkLovers(response map[string]interface{}, candidates []string) *LocalText {
+	value, present := response["targets"]
+	if !present {
+		return nil
+	}
+	targets, ok := value.([]interface{})
+	if !ok || len(targets) != 2 {
+		return reject("reject.two_lovers")
+	}
+	first, _ := targets[0].(string)
+	second, _ := targets[1].(string)
+	if !containsString(candidates, first) || !containsString(candidates, second) {
+		return reject("reject.invalid_lovers")
+	}
+	if first == second {
+		return reject("reject.same_lover")
+	}
+	return nil
+}
+
+// 按提示中给出的药剂状态和目标校验女巫的回复
+func checkWitch(prompt, response map[string]interface{}) *LocalText {
+	dead, _ := prompt["dead_players"].([]string)
+	alive, _ := prompt["alive_players"].([]string)
+	canSave, _ := prompt["can_save"].(bool)
+	hasPoison, _ := prompt["has_poison"].(bool)
+	onePotion, _ := prompt["one_potion"].(bool)
+
+	save, _ := response["save"].(string)
+	poison, _ := response["poison"].(string)
+	if save != "" {
+		if !canSave {
+			return reject("reject.no_antidote")
+		}
+		if reason := checkChoice(response, "save", dead); reason != nil {
+			return reason
+		}
+	}
+	if poison != "" {
+		if !hasPoison {
+			return reject("reject.no_poison")
+		}
+		if reason := checkChoice(response, "poison", alive); reason != nil {
+			return reason
+		}
+	}
+	if save != "" && poison != "" && onePotion {
+		return reject("reject.one_potion")
+	}
+	return nil
+}
+
+// 死亡玩家发动技能的提示，不会被狼人自爆打断
+var skillPrompts = map[string]bool{
+	"hunter_shoot":     true,
+	"badge_transfer":   true,
+	"wolf_king_target": true,
+}
+
+// 获取打断玩家当前提示的信号，技能提示返回nil
+func (s *GameServer) promptInterrupt(client *ClientConnection) chan struct{} {
+	if msgType, _ := client.pending["type"].(string); skillPrompts[msgType] {
+		return nil
+	}
+	return s.interruptChan()
+}
+
+// 在指定时间内接收消息，第二个返回值表示是否超时
+func (s *GameServer) receiveWithin(index int, timeout time.Duration) (map[string]interface{}, bool) {
+	if index >= 0 && index < len(s.clients) {
+		interrupt := s.promptInterrupt(s.clients[index])
+		select {
+		case message, ok := <-s.clients[index].inbox:
+			if !ok {
+				s.game.Log("接收消息失败: 连接已关闭")
+				return nil, false
+			}
+			return message, false
+		case <-time.After(timeout):
+			return nil, true
+		case <-interrupt:
+			return nil, false
+		}
+	}
+	return nil, false
+}
+
+// 持续读取客户端消息，自爆请求交给白天流程处理，其余消息放入收件箱
+func (s *GameServer) readLoop(client *ClientConnection) {
+	defer close(client.inbox)
+
+	for {
+		var message map[string]interface{}
+		if err := client.decoder.Decode(&message); err != nil {
+			if s.running.Load() {
+				s.game.Log(fmt.Sprintf("客户端连接断开: %v", err))
+			}
+			return
+		}
+
+		msgType, _ := message["type"].(string)
+		if msgType == "my_knowledge" {
+			s.SendKnowledge(client)
+			continue
+		}
+		if msgType == "wolf_chat" {
+			text, _ := message["text"].(string)
+			s.RelayWolfChat(client.player, text)
+			continue
+		}
+		if msgType == "explode" {
+			target, _ := message["target"].(string)
+			req := explodeRequest{player: client.player, target: target}
+			if !s.explodeOpen() {
+				s.rejectExplode(req, reject("reject.explode_closed"))
+				continue
+			}
+			select {
+			case s.explodes <- req:
+			default:
+				s.rejectExplode(req, reject("reject.explode_closed"))
+			}
+			continue
+		}
+
+		// 不在自己发言时间内的发言直接丢弃，避免被当作其他提示的回复
+		if (msgType == "speech" || msgType == "end_speech") && s.currentSpeaker() != client.player {
+			continue
+		}
+
+		select {
+		case client.inbox <- message:
+		default:
+			s.game.Log("客户端消息积压，已丢弃")
+		}
+	}
+}
+
+// 加入观战者，口令与本局的上帝视角口令一致时获得上帝视角，随后补发已推送过的事件和当前状态
+func (s *GameServer) AddSpectator(conn net.Conn, decoder *json.Decoder, message map[string]interface{}) {
+	name, _ := message["name"].(string)
+	token, _ := message["god_token"].(string)
+	spectator := &Spectator{
+		conn:   conn,
+		Name:   name,
+		God:    token != "" && token == s.game.Options.GodToken,
+		locale: DefaultLocale,
+	}
+	if locale, ok := message["locale"].(string); ok {
+		spectator.locale = ParseLocale(locale)
+	}
+	conn.SetReadDeadline(time.Time{})
+
+	// 观战者只记入服务器日志，不进入对局记录，保证同一种子的对局日志与观战情况无关
+	log.Printf("端口 %d: 观战者 %s 加入 (%s): %v\n", s.port, name, spectator.View(), conn.RemoteAddr())
+
+	s.spectatorLock.Lock()
+	history := []GameEvent{}
+	for _, e := range s.game.EventsSince(0)[:s.streamed] {
+		if spectator.CanSee(e) {
+			history = append(history, e)
+		}
+	}
+	s.sendSpectator(spectator, catalogFor(spectator.locale))
+	s.sendSpectator(spectator, map[string]interface{}{
+		"type":      "spectate",
+		"view":      spectator.View(),
+		"seats":     s.seats(),
+		"day_count": s.game.DayCount,
+		"events":    history,
+	})
+	if s.running.Load() {
+		s.sendSpectator(spectator, s.spectatorStatus(spectator))
+	}
+	s.spectators = append(s.spectators, spectator)
+	s.spectatorLock.Unlock()
+
+	go s.spectatorReadLoop(spectator, decoder)
+}
+
+// 游戏开始后继续接受观战者连接，以玩家身份加入的连接会被拒绝
+func (s *GameServer) acceptSpectators() {
+	s.listener.(*net.TCPListener).SetDeadline(time.Time{})
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		go func(conn net.Conn) {
+			decoder := json.NewDecoder(conn)
+			conn.SetReadDeadline(time.Now().Add(10 * time.Second))
+			var message map[string]interface{}
+			if err := decoder.Decode(&message); err != nil {
+				conn.Close()
+				return
+			}
+			if spectate, _ := message["spectate"].(bool); spectate {
+				s.AddSpectator(conn, decoder, message)
+				return
+			}
+
+			locale, _ := message["locale"].(string)
+			json.NewEncoder(conn).Encode(localize(map[string]interface{}{
+				"type":   "join_rejected",
+				"reason": Text("reject.game_started"),
+			}, ParseLocale(locale)))
+			conn.Close()
+		}(conn)
+	}
+}
+
+// 读取并丢弃观战者发送的消息，连接断开后移除观战者
+func (s *GameServer) spectatorReadLoop(spectator *Spectator, decoder *json.Decoder) {
+	for {
+		var message map[string]interface{}
+		if err := decoder.Decode(&message); err != nil {
+			break
+		}
+	}
+
+	s.spectatorLock.Lock()
+	defer s.spectatorLock.Unlock()
+	for i, sp := range s.spectators {
+		if sp == spectator {
+			s.spectators = append(s.spectators[:i], s.spectators[i+1:]...)
+			log.Printf("端口 %d: 观战者 %s 离开\n", s.port, spectator.Name)
+			break
+		}
+	}
+}
+
+// 发送消息给观战者，调用时已持有 spectatorLock
+func (s *GameServer) sendSpectator(spectator *Spectator, message map[string]interface{}) {
+	if err := json.NewEncoder(spectator.conn).Encode(localize(message, spectator.locale)); err != nil {
+		log.Printf("端口 %d: 发送观战消息失败: %v\n", s.port, err)
+	}
+}
+
+// 把广播消息转发给观战者，行动提示不会发给观战者
+func (s *GameServer) broadcastSpectators(message map[string]interface{}) {
+	if msgType, _ := message["type"].(string); promptTypes[msgType] || msgType == "wait_confirm" {
+		return
+	}
+	s.flushEvents()
+
+	s.spectatorLock.Lock()
+	defer s.spectatorLock.Unlock()
+	for _, sp := range s.spectators {
+		s.sendSpectator(sp, message)
+	}
+}
+
+// 开始向观战者推送新事件，返回的函数用于推送剩余事件并停止
+func (s *GameServer) streamEvents() func() {
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+
+	go func() {
+		defer close(stopped)
+		for {
+			select {
+			case <-s.game.updates:
+				s.flushEvents()
+			case <-done:
+				s.flushEvents()
+				return
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
+
+// 按记录顺序把尚未推送的事件发给能看到它的观战者
+func (s *GameServer) flushEvents() {
+	s.spectatorLock.Lock()
+	defer s.spectatorLock.Unlock()
+
+	events := s.game.EventsSince(s.streamed)
+	s.streamed += len(events)
+	for _, sp := range s.spectators {
+		for _, e := range events {
+			if sp.CanSee(e) {
+				s.sendSpectator(sp, map[string]interface{}{
+					"type":  "event",
+					"event": e,
+				})
+			}
+		}
+	}
+}
+
+// 向所有观战者发送游戏状态快照
+func (s *GameServer) SendSpectatorStatus() {
+	s.flushEvents()
+
+	s.spectatorLock.Lock()
+	defer s.spectatorLock.Unlock()
+	for _, sp := range s.spectators {
+		s.sendSpectator(sp, s.spectatorStatus(sp))
+	}
+}
+
+// 观战者看到的游戏状态，公开视角只显示公开翻牌的身份，上帝视角显示所有身份、情侣和女巫的药剂
+func (s *GameServer) spectatorStatus(spectator *Spectator) map[string]interface{} {
+	playersInfo := [][]interface{}{}
+	lovers := []string{}
+	for _, p := range s.game.Players {
+		role := RoleUnknown
+		if spectator.God || p.Revealed {
+			role = p.Role.GetID()
+		}
+		playersInfo = append(playersInfo, []interface{}{p.Name, role, p.Alive, p.Sheriff})
+		if p.Lover != nil {
+			lovers = append(lovers, p.ID)
+		}
+	}
+
+	status := map[string]interface{}{
+		"type":      "game_status",
+		"spectator": true,
+		"view":      spectator.View(),
+		"seats":     s.seats(),
+		"players":   playersInfo,
+		"day_count": s.game.DayCount,
+	}
+	if spectator.God {
+		status["lovers"] = lovers
+		for _, p := range s.game.Players {
+			if witch, ok := p.Role.(*Witch); ok {
+				status["potions"] = PotionStatus{Antidote: witch.HasAntidote, Poison: witch.HasPoison}
+			}
+		}
+	}
+	return status
+}
+
+// 关闭所有观战者的连接
+func (s *GameServer) closeSpectators() {
+	s.spectatorLock.Lock()
+	defer s.spectatorLock.Unlock()
+	for _, sp := range s.spectators {
+		sp.conn.Close()
+	}
+}
+
+// 回复玩家的知识查询，可以在游戏中随时发送
+func (s *GameServer) SendKnowledge(client *ClientConnection) {
+	if client.player == nil {
+		return
+	}
+	for i, c := range s.clients {
+		if c == client {
+			s.SendMessage(map[string]interface{}{
+				"type":      "my_knowledge",
+				"player_id": client.player.ID,
+				"knowledge": client.player.Knowledge.Snapshot(),
+			}, i)
+			return
+		}
+	}
+}
+
+// 获取当前发言的玩家
+func (s *GameServer) currentSpeaker() *Player {
+	s.stateLock.Lock()
+	defer s.stateLock.Unlock()
+	return s.speaker
+}
+
+// 设置当前发言的玩家
+func (s *GameServer) setSpeaker(player *Player) {
+	s.stateLock.Lock()
+	defer s.stateLock.Unlock()
+	s.speaker = player
+}
+
+// 开放或关闭狼人夜间频道
+func (s *GameServer) setWolfChannel(open bool) {
+	s.stateLock.Lock()
+	defer s.stateLock.Unlock()
+	s.wolfChannel = open
+}
+
+// 判断狼人夜间频道是否开放
+func (s *GameServer) wolfChannelOpen() bool {
+	s.stateLock.Lock()
+	defer s.stateLock.Unlock()
+	return s.wolfChannel
+}
+
+// 判断白天是否已被自爆打断
+func (s *GameServer) interrupted() bool {
+	return isClosed(s.interruptChan())
+}
+
+// 判断信号是否已经关闭，nil表示没有信号
+func isClosed(signal chan struct{}) bool {
+	if signal == nil {
+		return false
+	}
+	select {
+	case <-signal:
+		return true
+	default:
+		return false
+	}
+}
+
+// 开始本轮白天，从警长竞选或公布死讯起狼人可以随时自爆，直到白天结束
+func (s *GameServer) beginDay() {
+	if s.stopWatching == nil {
+		s.stopWatching = s.watchExplosions()
+	}
+}
+
+// 结束本轮白天，返回打断白天的自爆请求
+func (s *GameServer) endDay() *explodeRequest {
+	if s.stopWatching == nil {
+		return nil
+	}
+	explosion := s.stopWatching()
+	s.stopWatching = nil
+	return explosion
+}
+
+// 获取当前的打断信号，不在可打断阶段时返回nil
+func (s *GameServer) interruptChan() chan struct{} {
+	s.stateLock.Lock()
+	defer s.stateLock.Unlock()
+	return s.interrupt
+}
+
+// 判断当前是否接受自爆请求：白天已开始且尚未被自爆打断
+func (s *GameServer) explodeOpen() bool {
+	return s.interruptChan() != nil && !s.interrupted()
+}
+
+// 拒绝无法生效的自爆请求并告知发起的玩家
+func (s *GameServer) rejectExplode(req explodeRequest, reason *LocalText) {
+	s.game.Log(fmt.Sprintf("%s 的自爆请求被拒绝: %s", req.player.Name, reason.Render(DefaultLocale)))
+	if idx := s.clientIndex(req.player); idx >= 0 {
+		s.SendMessage(map[string]interface{}{
+			"type":   "action_rejected",
+			"prompt": "explode",
+			"reason": *reason,
+		}, idx)
+	}
+}
+
+// 开始监听狼人自爆，返回的函数用于停止监听并取得打断白天的自爆请求
+func (s *GameServer) watchExplosions() func() *explodeRequest {
+	// 拒绝上一轮白天结束时仍在排队的自爆请求
+	for drained := false; !drained; {
+		select {
+		case req := <-s.explodes:
+			s.rejectExplode(req, reject("reject.explode_closed"))
+		default:
+			drained = true
+		}
+	}
+
+	interrupt := make(chan struct{})
+	done := make(chan struct{})
+	result := make(chan *explodeRequest, 1)
+
+	s.stateLock.Lock()
+	s.interrupt = interrupt
+	s.stateLock.Unlock()
+
+	go func() {
+		for {
+			select {
+			case req := <-s.explodes:
+				if !req.player.Alive || !req.player.IsWolf() {
+					s.rejectExplode(req, reject("reject.cannot_explode"))
+					continue
+				}
+				close(interrupt)
+				result <- &req
+				return
+			case <-done:
+				result <- nil
+				return
+			}
+		}
+	}()
+
+	return func() *explodeRequest {
+		close(done)
+		req := <-result
+
+		s.stateLock.Lock()
+		s.interrupt = nil
+		s.stateLock.Unlock()
+		return req
+	}
+}
+
+// 发送游戏状态
+func (s *GameServer) SendGameStatus() {
+	for i, client := range s.clients {
+		player := client.player
+		if player == nil {
+			continue
+		}
+
+		// 其他玩家的身份只按该玩家的知识显示
+		knowledge := player.Knowledge.Snapshot()
+		playersInfo := [][]interface{}{}
+		for _, p := range s.game.Players {
+			role := RoleUnknown
+			if known, ok := knowledge.Roles[p.ID]; ok {
+				role = known
+			}
+			playersInfo = append(playersInfo, []interface{}{p.Name, role, p.Alive, p.Sheriff})
+		}
+
+		status := map[string]interface{}{
+			"type":      "game_status",
+			"player_id": player.ID,
+			"seats":     s.seats(),
+			"role":      player.Role.GetID(),
+			"role_name": Text("role." + player.Role.GetID()),
+			"lover":     knowledge.Lover,
+			"players":   playersInfo,
+			"revealed":  knowledge.Revealed,
+			"knowledge": knowledge,
+			"can_vote":  player.CanVote,
+			"day_count": s.game.DayCount,
+		}
+
+		s.SendMessage(status, i)
+	}
+	s.SendSpectatorStatus()
+}
+
+// 座位表，协议消息中的玩家ID与显示名称的对应关系
+func (s *GameServer) seats() []map[string]interface{} {
+	seats := []map[string]interface{}{}
+	for _, p := range s.game.Players {
+		seats = append(seats, map[string]interface{}{
+			"id":   p.ID,
+			"name": p.Name,
+		})
+	}
+	return seats
+}
+
+// 运行游戏，按阶段状态机推进直到游戏结束
+func (s *GameServer) RunGameLoop() {
+	s.game.Log("=== 狼人杀游戏开始 ===")
+
+	for s.phases.Current != PhaseGameOver {
+		phase := s.phases.Get(s.phases.Current)
+		if phase == nil {
+			s.game.Log(fmt.Sprintf("阶段 %s 未注册，游戏终止", s.phases.Current))
+			break
+		}
+
+		s.game.Log(fmt.Sprintf("\n[阶段] %s", phase.Name))
+		s.takeOverStalledBots()
+		next := phase.Run()
+		if err := s.phases.Transition(next); err != nil {
+			s.game.Log(fmt.Sprintf("阶段转换异常: %v", err))
+		}
+
+		s.BroadcastMessage(map[string]interface{}{
+			"type":      "phase_change",
+			"phase":     s.phases.Current,
+			"previous":  s.phases.Previous,
+			"day_count": s.game.DayCount,
+		})
+	}
+
+	s.BroadcastMessage(map[string]interface{}{
+		"type":    "game_end",
+		"winner":  s.game.Winner,
+		"winners": playerIDs(s.game.WinningPlayers()),
+		"text":    Text("game_end", Text("faction."+s.game.Winner)),
+	})
+}
+
+// 处理警长竞选：玩家报名上警、依次发言、可以退水，未上警的玩家在候选人中投票，
+// 平票的候选人进入第二轮投票，再次平票或无人竞选时警徽流失
+func (s *GameServer) HandleSheriffElection() {
+	// 竞选过程中有狼人自爆时中止竞选，警徽流失
+	aborted := func() bool {
+		if !s.interrupted() {
+			return false
+		}
+		s.game.Log("警长竞选被自爆打断，警徽流失")
+		s.announceSheriff(nil)
+		return true
+	}
+
+	runners := s.collectSheriffSignups()
+	if aborted() {
+		return
+	}
+	if len(runners) == 0 {
+		s.game.Log("没有玩家上警，警徽流失")
+		s.announceSheriff(nil)
+		return
+	}
+
+	seconds := s.game.Options.SpeechSeconds
+	if seconds <= 0 {
+		seconds = DefaultSpeechSeconds
+	}
+	for _, candidate := range runners {
+		if s.interrupted() {
+			break
+		}
+		s.HandleSpeech(candidate, time.Duration(seconds)*time.Second)
+	}
+	if aborted() {
+		return
+	}
+
+	candidates := s.collectSheriffWithdrawals(runners)
+	if aborted() {
+		return
+	}
+	switch len(candidates) {
+	case 0:
+		s.game.Log("所有候选人都已退水，警徽流失")
+		s.announceSheriff(nil)
+		return
+	case 1:
+		s.game.Log(fmt.Sprintf("%s 是唯一的候选人，自动当选", candidates[0].Name))
+		s.game.SetSheriff(candidates[0])
+		s.announceSheriff(candidates[0])
+		return
+	}
+
+	// 上过警的玩家（包括退水的）没有投票权
+	voters := []*Player{}
+	for _, p := range s.game.Players {
+		if p.Alive && !containsPlayer(runners, p) {
+			voters = append(voters, p)
+		}
+	}
+
+	for round := 1; round <= 2; round++ {
+		s.collectSheriffVotes(candidates, voters)
+		if aborted() {
+			return
+		}
+		tied := s.game.ElectSheriff(candidates)
+		if tied == nil {
+			s.announceSheriff(s.game.Sheriff)
+			return
+		}
+		candidates = tied
+		s.game.Log(fmt.Sprintf("第 %d 轮警长投票平票: %v", round, playerNames(tied)))
+	}
+
+	s.game.Log("警长投票再次平票，警徽流失")
+	s.announceSheriff(nil)
+}
+
+// 收集上警报名，返回按座位排序的候选人
+func (s *GameServer) collectSheriffSignups() []*Player {
+	var wg sync.WaitGroup
+	var lock sync.Mutex
+	running := map[*Player]bool{}
+
+	for i, client := range s.clients {
+		if client.player != nil && client.player.Alive && !client.player.IsAI {
+			wg.Add(1)
+			go func(idx int, p *Player) {
+				defer wg.Done()
+				s.SendMessage(map[string]interface{}{"type": "sheriff_signup"}, idx)
+				response := s.ReceiveMessage(idx)
+				if run, _ := response["run"].(bool); run {
+					lock.Lock()
+					running[p] = true
+					lock.Unlock()
+				}
+			}(i, client.player)
+		}
+	}
+
+	wg.Wait()
+
+	// AI玩家按策略决定是否上警，没有回复的外部机器人由AI接管后一起决定
+	s.takeOverStalledBots()
+	for _, p := range s.game.Players {
+		if p.Alive && p.IsAI && p.AI().RunForSheriff(p.View()) {
+			running[p] = true
+		}
+	}
+
+	runners := []*Player{}
+	for _, p := range s.game.Players {
+		if running[p] {
+			runners = append(runners, p)
+			s.game.Log(fmt.Sprintf("%s 上警", p.Name))
+		}
+	}
+	s.BroadcastMessage(map[string]interface{}{
+		"type":       "sheriff_candidates",
+		"candidates": playerIDs(runners),
+	})
+	return runners
+}
+
+// 候选人发言结束后可以退水，返回仍在竞选的候选人
+func (s *GameServer) collectSheriffWithdrawals(runners []*Player) []*Player {
+	var wg sync.WaitGroup
+	var lock sync.Mutex
+	withdrawn := map[*Player]bool{}
+	ids := playerIDs(runners)
+
+	for _, p := range runners {
+		if p.IsAI {
+			continue
+		}
+		idx := s.clientIndex(p)
+		if idx < 0 {
+			continue
+		}
+		wg.Add(1)
+		go func(idx int, p *Player) {
+			defer wg.Done()
+			s.SendMessage(map[string]interface{}{
+				"type":       "sheriff_withdraw",
+				"candidates": ids,
+			}, idx)
+			response := s.ReceiveMessage(idx)
+			if withdraw, _ := response["withdraw"].(bool); withdraw {
+				lock.Lock()
+				withdrawn[p] = true
+				lock.Unlock()
+			}
+		}(idx, p)
+	}
+	wg.Wait()
+
+	// AI候选人按策略决定是否退水，没有回复的外部机器人由AI接管后一起决定
+	s.takeOverStalledBots()
+	for _, p := range runners {
+		if p.IsAI && p.AI().WithdrawSheriff(p.View()) {
+			withdrawn[p] = true
+		}
+	}
+
+	candidates := []*Player{}
+	for _, p := range runners {
+		if withdrawn[p] {
+			s.game.Log(fmt.Sprintf("%s 退水", p.Name))
+			s.BroadcastMessage(map[string]interface{}{
+				"type":   "sheriff_withdrawn",
+				"player": p.ID,
+			})
+			continue
+		}
+		candidates = append(candidates, p)
+	}
+	return candidates
+}
+
+// 收集一轮警长选票，voters 只能投给 candidates 中的一人，也可以弃票
+func (s *GameServer) collectSheriffVotes(candidates, voters []*Player) {
+	var wg sync.WaitGroup
+	choices := map[*Player]*Player{}
+
+	for _, voter := range voters {
+		if voter.IsAI {
+			continue
+		}
+		if idx := s.clientIndex(voter); idx >= 0 {
+			wg.Add(1)
+			go func(idx int, p *Player) {
+				defer wg.Done()
+				if target := s.PlayerSheriffVote(idx, p, candidates); target != nil {
+					s.voteLock.Lock()
+					choices[p] = target
+					s.voteLock.Unlock()
+				}
+			}(idx, voter)
+		}
+	}
+
+	wg.Wait()
+
+	// AI玩家投票，没有回复的外部机器人由AI接管后补投
+	s.takeOverStalledBots()
+	ids := playerIDs(candidates)
+	for _, voter := range voters {
+		if !voter.IsAI {
+			continue
+		}
+		target := s.game.FindPlayer(voter.AI().SheriffVote(voter.View(), ids))
+		if target != nil && containsPlayer(candidates, target) {
+			choices[voter] = target
+		}
+	}
+	s.countVotes(voters, choices, false)
+}
+
+// 按座位顺序计入一轮收集到的选票，没有做出有效选择的投票人视为弃票；
+// 选票在全部收集完成后才计入和记录，保证同一种子的日志顺序一致
+func (s *GameServer) countVotes(voters []*Player, choices map[*Player]*Player, sheriffWeight bool) {
+	for _, voter := range voters {
+		target := choices[voter]
+		if target == nil {
+			s.game.Log(fmt.Sprintf("%s 弃票", voter.Name))
+			continue
+		}
+		voteValue := 1.0
+		if sheriffWeight && voter.Sheriff {
+			voteValue = 1.5
+		}
+		target.Votes += voteValue
+		s.game.Log(fmt.Sprintf("%s (%s) 投票给 %s", voter.Name, voter.Role.GetName(), target.Name))
+	}
+}
+
+// 公布警长竞选结果，sheriff 为nil表示警徽流失
+func (s *GameServer) announceSheriff(sheriff *Player) {
+	id := ""
+	if sheriff != nil {
+		id = sheriff.ID
+	}
+	s.BroadcastMessage(map[string]interface{}{
+		"type":    "sheriff_result",
+		"sheriff": id,
+	})
+}
+
+// 处理玩家警长投票，返回选择的候选人，弃票或无效选择时返回nil
+func (s *GameServer) PlayerSheriffVote(playerIndex int, player *Player, candidates []*Player) *Player {
+	s.SendMessage(map[string]interface{}{
+		"type":       "sheriff_election",
+		"candidates": playerIDs(candidates),
+	}, playerIndex)
+
+	response := s.ReceiveMessage(playerIndex)
+	if response != nil {
+		if targetID, ok := response["vote"].(string); ok {
+			for _, p := range candidates {
+				if p.ID == targetID {
+					return p
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// 处理玩家白天投票，messageType 为 day_vote 或 pk_vote，只能投给 candidates 中的玩家；
+// 返回选择的玩家，弃票或无效选择时返回nil
+func (s *GameServer) PlayerDayVote(playerIndex int, player *Player, candidates []*Player, messageType string) *Player {
+	if !player.CanVote {
+		return nil
+	}
+
+	ids := []string{}
+	for _, p := range candidates {
+		if p != player {
+			ids = append(ids, p.ID)
+		}
+	}
+
+	s.SendMessage(map[string]interface{}{
+		"type":       messageType,
+		"candidates": ids,
+	}, playerIndex)
+
+	response := s.ReceiveMessage(playerIndex)
+	if response != nil {
+		if targetID, ok := response["vote"].(string); ok {
+			for _, p := range candidates {
+				if p.ID == targetID && p != player {
+					return p
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// 处理夜晚阶段，各阶段只收集行动，天亮时统一结算
+// 入夜阶段，重置当晚的行动
+func (s *GameServer) phaseNightfall() string {
+	s.game.BeginNight()
+	return ""
+}
+
+// 丘比特阶段（仅第一晚）
+func (s *GameServer) phaseNightCupid() string {
+	if s.game.DayCount != 1 {
+		return ""
+	}
+	s.collectNightActions("cupid", (*Player).IsCupid)
+
+	// 未做出有效选择的真人丘比特随机连接，随机数只在游戏主流程中使用
+	for _, p := range s.game.Players {
+		if p.Alive && p.IsCupid() && !p.IsAI && !s.game.Night.Submitted(p, ActionLink) {
+			s.randomLink(p)
+		}
+	}
+
+	// 通知真人情侣
+	for _, lover := range s.game.Players {
+		if lover.Lover == nil || !lover.Alive {
+			continue
+		}
+		if idx := s.clientIndex(lover); idx >= 0 {
+			s.SendMessage(map[string]interface{}{
+				"type":          "lovers",
+				"partner":       lover.Lover.ID,
+				"partner_role":  lover.Lover.Role.GetID(),
+				"third_faction": s.game.LoversFaction,
+			}, idx)
+		}
+	}
+	return ""
+}
+
+// 为丘比特随机连接两名存活玩家
+func (s *GameServer) randomLink(cupid *Player) {
+	candidates := []string{}
+	for _, p := range s.game.Players {
+		if p.Alive {
+			candidates = append(candidates, p.ID)
+		}
+	}
+	if len(candidates) < 2 {
+		return
+	}
+	s.game.Log(fmt.Sprintf("丘比特 %s (真人) 未做出有效选择，随机连接情侣", cupid.Name))
+	perm := s.game.rng.Perm(len(candidates))
+	s.game.SubmitNightAction(cupid, ActionLink, candidates[perm[0]], candidates[perm[1]])
+}
+
+// 守卫阶段
+func (s *GameServer) phaseNightGuard() string {
+	s.collectNightActions("guard", (*Player).IsGuard)
+	return ""
+}
+
+// 狼人阶段，狼人在夜间频道中商量后统计出狼队的击杀目标并通知所有存活狼人
+func (s *GameServer) phaseNightWolf() string {
+	s.HandleWolfChannel()
+	kill := s.game.TallyWolfVotes()
+	s.SendToWolves(map[string]interface{}{
+		"type":   "wolf_vote_update",
+		"votes":  s.game.WolfVotes(),
+		"target": kill,
+		"final":  true,
+	})
+	return ""
+}
+
+// 真人狼人在夜间频道中的一次投票
+type wolfVote struct {
+	player *Player
+	target string
+}
+
+// 狼人夜间频道: 存活狼人可以私聊并实时看到队友的选择，可以随时改票，
+// 全体存活狼人选择同一目标或商量时间结束时关闭
+func (s *GameServer) HandleWolfChannel() {
+	s.setWolfChannel(true)
+	defer s.setWolfChannel(false)
+
+	// AI狼人先给出选择，真人狼人可以参考
+	s.aiWolfVotes()
+
+	candidates := []string{}
+	wolves := []*Player{}
+	for _, p := range s.game.Players {
+		if p.Alive && p.IsWolf() {
+			wolves = append(wolves, p)
+		} else if p.Alive {
+			candidates = append(candidates, p.ID)
+		}
+	}
+
+	seconds := s.game.Options.WolfSeconds
+	if seconds <= 0 {
+		seconds = DefaultWolfSeconds
+	}
+	deadline := time.Now().Add(time.Duration(seconds) * time.Second)
+
+	votes := make(chan wolfVote)
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	humans := []int{}
+	for i, client := range s.clients {
+		if p := client.player; p != nil && p.Alive && p.IsWolf() && !p.IsAI {
+			s.SendMessage(map[string]interface{}{
+				"type":       "night_action",
+				"action":     "werewolf",
+				"candidates": candidates,
+				"teammates":  playerIDs(wolves),
+				"votes":      s.game.WolfVotes(),
+				"seconds":    seconds,
+			}, i)
+			humans = append(humans, i)
+			wg.Add(1)
+			go func(idx int) {
+				defer wg.Done()
+				s.collectWolfVotes(idx, deadline, done, votes)
+			}(i)
+		}
 	}
-	s.listener = listener
-	defer listener.Close()
 
-	s.game.Log(fmt.Sprintf("服务器启动在 %s, 等待玩家连接...", addr))
+	timer := time.NewTimer(time.Until(deadline))
+	defer timer.Stop()
+	for open := len(humans) > 0; open && !s.game.WolvesAgree(); {
+		select {
+		case v := <-votes:
+			s.submitNightResponse(v.player, "werewolf", map[string]interface{}{"target": v.target})
+			s.aiWolfVotes()
+		case <-timer.C:
+			s.game.Log("狼人商量时间结束")
+			open = false
+		}
+	}
+	close(done)
+	wg.Wait()
 
-	// 设置监听超时，避免无限等待
-	listener.(*net.TCPListener).SetDeadline(time.Now().Add(30 * time.Second))
+	// 没有投票的外部机器人视为超时，由内置AI接管并补上投票
+	tookOver := false
+	for _, idx := range humans {
+		client := s.clients[idx]
+		if p := client.player; p.IsBot && !p.IsAI && !s.game.Night.Submitted(p, ActionWolfVote) {
+			s.takeOverBot(client)
+			tookOver = true
+		}
+		client.pending = nil
+	}
+	if tookOver {
+		s.aiWolfVotes()
+	}
+}
 
-	// 接受玩家连接
-	for i := 0; i < numRealPlayers; i++ {
-		conn, err := listener.Accept()
-		if err != nil {
-			// 如果是因为超时导致的错误，就继续下一步
-			if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
-				s.game.Log(fmt.Sprintf("等待玩家连接超时，将自动添加 AI 替代"))
-				break
+// 在频道开放期间接收一名真人狼人的投票，无效的投票会被拒绝
+func (s *GameServer) collectWolfVotes(index int, deadline time.Time, done <-chan struct{}, votes chan<- wolfVote) {
+	client := s.clients[index]
+	for {
+		select {
+		case message, ok := <-client.inbox:
+			if !ok {
+				return
 			}
-			return nil, fmt.Errorf("接受连接失败: %v", err)
+			if reason := validateResponse(client.pending, message); reason != nil {
+				s.rejectResponse(index, reason, deadline)
+				continue
+			}
+			target, _ := message["target"].(string)
+			if target == "" {
+				continue
+			}
+			select {
+			case votes <- wolfVote{player: client.player, target: target}:
+			case <-done:
+				return
+			}
+		case <-done:
+			return
 		}
-		s.game.Log(fmt.Sprintf("玩家%d已连接: %v", i+1, conn.RemoteAddr()))
+	}
+}
 
-		client := &ClientConnection{
-			conn:   conn,
-			player: nil,
+// AI狼人按策略投票，已投票的AI狼人会在队友改票后重新选择
+func (s *GameServer) aiWolfVotes() {
+	votes := s.game.WolfVotes()
+	for _, p := range s.game.Players {
+		if p.Alive && p.IsWolf() && p.IsAI {
+			response := p.NightAction(s.game.Players)
+			if target, _ := response["target"].(string); target != "" && target != votes[p.ID] {
+				s.submitNightResponse(p, "werewolf", response)
+			}
 		}
-		s.clients = append(s.clients, client)
 	}
+}
+
+// 在狼人夜间频道中转发狼人的私聊，频道关闭时或非存活狼人的私聊会被丢弃
+func (s *GameServer) RelayWolfChat(wolf *Player, text string) {
+	if wolf == nil || !wolf.Alive || !wolf.IsWolf() || text == "" || !s.wolfChannelOpen() {
+		return
+	}
+	s.game.Log(fmt.Sprintf("狼人 %s 夜聊: %s", wolf.Name, text))
+	s.game.Record(GameEvent{Type: EventWolfChat, Actor: wolf.ID, Visibility: VisibilityWolves, Data: map[string]interface{}{"text": text}})
+	s.SendToWolves(map[string]interface{}{
+		"type": "wolf_chat",
+		"from": wolf.ID,
+		"text": text,
+	})
+}
 
-	// 接收玩家名称
-	players := []*Player{}
+// 发送消息给所有存活的狼人
+func (s *GameServer) SendToWolves(message map[string]interface{}) {
 	for i, client := range s.clients {
-		// 设置读取超时
-		client.conn.SetReadDeadline(time.Now().Add(10 * time.Second))
+		if p := client.player; p != nil && p.Alive && p.IsWolf() {
+			s.SendMessage(message, i)
+		}
+	}
+}
 
-		var message map[string]interface{}
-		if err := json.NewDecoder(client.conn).Decode(&message); err != nil {
-			s.game.Log(fmt.Sprintf("接收玩家名称失败，使用默认名称: %v", err))
-			message = map[string]interface{}{
-				"name": fmt.Sprintf("Player%d", i+1),
-			}
+// 女巫阶段
+func (s *GameServer) phaseNightWitch() string {
+	s.collectNightActions("witch", (*Player).IsWitch)
+	return ""
+}
+
+// 预言家阶段
+func (s *GameServer) phaseNightSeer() string {
+	s.collectNightActions("seer", (*Player).IsSeer)
+
+	// 查验提交时已经生效，立即通知存活的真人预言家
+	for _, check := range s.game.Night.Of(ActionCheck) {
+		target := s.game.FindPlayer(check.Target())
+		result := CheckResult(target)
+		if idx := s.clientIndex(check.Actor); idx >= 0 && check.Actor.Alive {
+			s.SendMessage(map[string]interface{}{
+				"type":   "seer_result",
+				"action": "seer",
+				"target": target.ID,
+				"result": result,
+				"text":   Text("seer_result", target.Name, Text("check."+result)),
+			}, idx)
 		}
+	}
+	return ""
+}
 
-		name, ok := message["name"].(string)
-		if !ok {
-			name = fmt.Sprintf("Player%d", i+1)
+// 收集符合条件的存活玩家的夜间行动，真人先行动，AI可以参考真人队友的选择；
+// 没有回复的外部机器人由AI接管后补上这次行动
+func (s *GameServer) collectNightActions(roleType string, match func(*Player) bool) {
+	s.runHumanNightActions(roleType, match)
+	s.takeOverStalledBots()
+	for _, p := range s.game.Players {
+		if p.Alive && p.IsAI && match(p) {
+			s.submitNightResponse(p, roleType, p.NightAction(s.game.Players))
 		}
+	}
+}
 
-		player := NewPlayer(name, false)
-		client.player = player
-		players = append(players, player)
-		s.game.AddPlayer(player)
+// 并发处理符合条件的存活真人玩家的夜间行动
+func (s *GameServer) runHumanNightActions(roleType string, match func(*Player) bool) {
+	var wg sync.WaitGroup
+	for i, client := range s.clients {
+		if client.player != nil && client.player.Alive && !client.player.IsAI && match(client.player) {
+			wg.Add(1)
+			go func(idx int, p *Player) {
+				defer wg.Done()
+				s.PlayerNightAction(idx, p, roleType)
+			}(i, client.player)
+		}
 	}
+	wg.Wait()
+}
 
-	// 发送等待确认消息
-	playerNames := []string{}
-	for _, p := range players {
-		playerNames = append(playerNames, p.Name)
+// 将玩家对夜间提示的回复转换为行动提交，AI与真人使用相同的回复格式，返回是否提交了有效行动
+func (s *GameServer) submitNightResponse(player *Player, roleType string, response map[string]interface{}) bool {
+	submit := func(kind string, targets ...string) bool {
+		if s.game.SubmitNightAction(player, kind, targets...) {
+			return true
+		}
+		s.game.Log(fmt.Sprintf("%s 的夜间行动 %s %v 无效", player.Name, kind, targets))
+		return false
 	}
 
-	s.BroadcastMessage(map[string]interface{}{
-		"type":    "wait_confirm",
-		"players": playerNames,
-	})
+	switch roleType {
+	case "cupid":
+		targets := []string{}
+		switch list := response["targets"].(type) {
+		case []string:
+			targets = list
+		case []interface{}:
+			for _, t := range list {
+				id, _ := t.(string)
+				targets = append(targets, id)
+			}
+		}
+		return len(targets) > 0 && submit(ActionLink, targets...)
+	case "guard":
+		// 空的回复表示空守，没有回复时不提交
+		if response == nil {
+			return false
+		}
+		targetID, _ := response["target"].(string)
+		return submit(ActionGuard, targetID)
+	case "werewolf":
+		// 狼人的选择实时同步给所有存活狼人
+		targetID, _ := response["target"].(string)
+		if targetID == "" || !submit(ActionWolfVote, targetID) {
+			return false
+		}
+		s.SendToWolves(map[string]interface{}{
+			"type":   "wolf_vote_update",
+			"wolf":   player.ID,
+			"target": targetID,
+			"votes":  s.game.WolfVotes(),
+			"final":  false,
+		})
+		return true
+	case "witch":
+		submitted := false
+		if saveID, _ := response["save"].(string); saveID != "" {
+			submitted = submit(ActionSave, saveID)
+		}
+		if poisonID, _ := response["poison"].(string); poisonID != "" {
+			submitted = submit(ActionPoison, poisonID) || submitted
+		}
+		return submitted
+	case "seer":
+		targetID, _ := response["target"].(string)
+		return targetID != "" && submit(ActionCheck, targetID)
+	}
+	return false
+}
 
-	// 接收确认
-	confirmations := []bool{}
-	for _, client := range s.clients {
-		client.conn.SetReadDeadline(time.Now().Add(10 * time.Second))
-		var message map[string]interface{}
-		if err := json.NewDecoder(client.conn).Decode(&message); err != nil {
-			s.game.Log(fmt.Sprintf("接收确认失败: %v", err))
-			confirmations = append(confirmations, false)
-			continue
+// 天亮阶段，结算夜晚行动，第一天进入警长选举
+func (s *GameServer) phaseDawn() string {
+	s.game.ResolveNight()
+
+	// 发送游戏状态更新
+	time.Sleep(1 * time.Second)
+	s.SendGameStatus()
+
+	if s.game.Sheriff == nil && !s.game.SheriffElect {
+		return PhaseSheriff
+	}
+	return PhaseAnnounce
+}
+
+// 警长选举阶段（只在第一天）
+func (s *GameServer) phaseSheriff() string {
+	s.beginDay()
+	s.game.Log("警长竞选，玩家报名上警")
+	s.HandleSheriffElection()
+	s.game.SheriffElect = true
+	return ""
+}
+
+// 公布死讯阶段，结算夜晚死亡玩家的技能；此时已被自爆打断的白天在死讯和遗言之后结束
+func (s *GameServer) phaseAnnounce() string {
+	s.beginDay()
+	firstNight := s.game.DayCount == 1
+	dead := s.HandleDeaths(s.game.DayActions())
+	if s.game.CheckGameEnd() {
+		return PhaseGameOver
+	}
+
+	switch s.game.Options.LastWords {
+	case LastWordsAllNights:
+		s.lastWords = dead
+	case LastWordsNoNights:
+		s.lastWords = nil
+	default:
+		if firstNight {
+			s.lastWords = dead
 		}
-		client.conn.SetReadDeadline(time.Time{}) // 重置超时
+	}
+	if len(s.lastWords) > 0 {
+		return PhaseLastWords
+	}
+	return ""
+}
 
-		confirm, ok := message["confirm"].(bool)
-		if !ok {
-			confirmations = append(confirmations, false)
-			continue
+// 讨论阶段，存活玩家按顺序轮流发言
+func (s *GameServer) phaseDiscussion() string {
+	s.beginDay()
+
+	seconds := s.game.Options.SpeechSeconds
+	if seconds <= 0 {
+		seconds = DefaultSpeechSeconds
+	}
+	for _, speaker := range s.speakingOrder() {
+		if s.interrupted() {
+			break
 		}
+		s.HandleSpeech(speaker, time.Duration(seconds)*time.Second)
+	}
 
-		confirmations = append(confirmations, confirm)
+	if s.interrupted() {
+		return s.finishDay(s.endDay())
 	}
+	return ""
+}
 
-	// 检查所有玩家是否确认
-	allConfirmed := true
-	for _, confirm := range confirmations {
-		if !confirm {
-			allConfirmed = false
+// 放逐投票阶段，投票期间狼人可以随时自爆打断白天
+func (s *GameServer) phaseVote() string {
+	s.beginDay()
+
+	candidates := []*Player{}
+	for _, p := range s.game.Players {
+		if p.Alive {
+			candidates = append(candidates, p)
+		}
+	}
+	s.collectDayVotes(candidates, nil, "day_vote")
+
+	// 多名玩家平票且有得票时进入PK，PK期间继续监听自爆
+	if leaders := s.game.topVoted(); !s.interrupted() && len(leaders) > 1 && leaders[0].Votes > 0 {
+		s.pkCandidates = leaders
+		s.game.resetVotes()
+		return PhasePK
+	}
+
+	// 有狼人自爆时跳过放逐投票
+	explosion := s.endDay()
+	if explosion == nil {
+		explosion = s.aiExplosion()
+	}
+	return s.finishDay(explosion)
+}
+
+// PK阶段：平票的玩家依次发言，其余玩家在他们之中重新投票，再次平票则无人出局
+func (s *GameServer) phasePK() string {
+	s.beginDay()
+
+	candidates := s.pkCandidates
+	s.pkCandidates = nil
+
+	s.game.Log(fmt.Sprintf("平票，%v 进入PK", playerNames(candidates)))
+	s.BroadcastMessage(map[string]interface{}{
+		"type":       "pk_start",
+		"candidates": playerIDs(candidates),
+	})
+
+	// PK发言时间为正常发言的一半
+	seconds := s.game.Options.SpeechSeconds / 2
+	if seconds <= 0 {
+		seconds = DefaultSpeechSeconds / 2
+	}
+	for _, speaker := range candidates {
+		if s.interrupted() {
 			break
 		}
+		s.HandleSpeech(speaker, time.Duration(seconds)*time.Second)
 	}
 
-	// 添加AI玩家
-	aiNames := []string{"Stephanie", "Wendy", "Elmy", "Sham", "Jeffry", "Kelly", "Tony", "Alice", "Bob", "Charlie"}
+	if !s.interrupted() {
+		s.collectDayVotes(candidates, candidates, "pk_vote")
+	}
 
-	// 计算需要添加的AI玩家数量
-	aiPlayersToAdd := numAIPlayers
-	if !allConfirmed {
-		// 如果有玩家未确认，则添加更多AI以满足总人数
-		aiPlayersToAdd = numRealPlayers + numAIPlayers
+	explosion := s.endDay()
+	if explosion == nil {
+		explosion = s.aiExplosion()
 	}
+	return s.finishDay(explosion)
+}
 
-	for i := 0; i < aiPlayersToAdd; i++ {
-		name := fmt.Sprintf("AI%d", i+1)
-		if i < len(aiNames) {
-			name = aiNames[i]
+// 收集一轮白天投票：存活、有投票权且不在 excluded 中的玩家投给 candidates 中的一人
+func (s *GameServer) collectDayVotes(candidates, excluded []*Player, messageType string) {
+	var wg sync.WaitGroup
+	choices := map[*Player]*Player{}
+
+	voters := []*Player{}
+	for _, p := range s.game.Players {
+		if p.Alive && p.CanVote && !containsPlayer(excluded, p) {
+			voters = append(voters, p)
+		}
+	}
+
+	// 处理人类玩家投票
+	for _, voter := range voters {
+		if voter.IsAI {
+			continue
+		}
+		if idx := s.clientIndex(voter); idx >= 0 {
+			wg.Add(1)
+			go func(idx int, p *Player) {
+				defer wg.Done()
+				if target := s.PlayerDayVote(idx, p, candidates, messageType); target != nil {
+					s.voteLock.Lock()
+					choices[p] = target
+					s.voteLock.Unlock()
+				}
+			}(idx, voter)
 		}
-		s.game.AddPlayer(NewPlayer(name, true))
 	}
 
-	// 分配角色
-	s.game.RandomAllocate()
+	wg.Wait()
+
+	// 处理AI玩家投票，没有回复的外部机器人由AI接管后补投，策略返回空或无效目标时视为弃票
+	s.takeOverStalledBots()
+	ids := playerIDs(candidates)
+	for _, voter := range voters {
+		if !voter.IsAI {
+			continue
+		}
+		target := s.game.FindPlayer(voter.AI().DayVote(voter.View(), ids))
+		if target != nil && target != voter && containsPlayer(candidates, target) {
+			choices[voter] = target
+		}
+	}
+
+	// 所有投票完成后按座位顺序计票
+	s.countVotes(voters, choices, true)
+}
+
+// 结束白天：有自爆时结算自爆，否则结算放逐投票，返回下一阶段
+func (s *GameServer) finishDay(explosion *explodeRequest) string {
+	next := ""
+	if explosion != nil {
+		s.game.resetVotes()
+		s.HandleExplode(*explosion)
+	} else if exiled := s.game.Vote(); exiled != nil {
+		s.lastWords = s.HandleDeaths([]*Player{exiled})
+		next = PhaseLastWords
+	}
+
+	// 发送游戏状态更新
+	time.Sleep(1 * time.Second)
 	s.SendGameStatus()
 
-	// 设置游戏事件
-	s.game.Events = []GameEvent{
-		NewNightEvent("黑夜", "狼人行动"),
-		NewDayEvent("白天", "讨论和投票"),
+	if s.game.CheckGameEnd() {
+		return PhaseGameOver
+	}
+	if explosion != nil {
+		return PhaseNightfall
 	}
+	return next
+}
 
-	// 运行游戏
-	s.running = true
-	s.RunGameLoop()
+// 计算发言顺序：有警长时由警长选择方向，从警长相邻座位开始、警长最后发言；
+// 没有警长时从最后死亡玩家的下一个座位开始
+func (s *GameServer) speakingOrder() []*Player {
+	players := s.game.Players
+	n := len(players)
+	if n == 0 {
+		return nil
+	}
 
-	// 准备结果
-	result.Duration = time.Since(startTime)
-	if s.game.WinnerIsWerewolf {
-		result.WinningFaction = "狼人"
-	} else {
-		result.WinningFaction = "好人"
+	start, step := 0, 1
+	sheriff := s.game.Sheriff
+	if sheriff != nil && !sheriff.Alive {
+		sheriff = nil
+	}
+	if sheriff != nil {
+		if s.chooseSpeechDirection(sheriff) == "left" {
+			step = -1
+		}
+		start = s.game.SeatOf(sheriff) + step
+	} else if s.game.LastDeath != nil {
+		start = s.game.SeatOf(s.game.LastDeath) + 1
 	}
 
-	for _, p := range s.game.Players {
-		result.Players = append(result.Players, PlayerInfo{
-			Name:   p.Name,
-			Role:   p.Role.GetName(),
-			Alive:  p.Alive,
-			IsWolf: p.IsWolf(),
-		})
+	order := []*Player{}
+	for i := 0; i < n; i++ {
+		p := players[((start+i*step)%n+n)%n]
+		if p.Alive && p != sheriff {
+			order = append(order, p)
+		}
 	}
+	if sheriff != nil {
+		order = append(order, sheriff)
+	}
+	return order
+}
 
-	result.Logs = s.game.Logs
+// 警长选择发言方向，left 为座位递减方向，right 为座位递增方向
+func (s *GameServer) chooseSpeechDirection(sheriff *Player) string {
+	direction := "right"
+	if idx := s.clientIndex(sheriff); idx >= 0 && !sheriff.IsAI {
+		s.SendMessage(map[string]interface{}{
+			"type":    "speech_direction",
+			"options": []string{"left", "right"},
+		}, idx)
+		if response := s.ReceiveMessage(idx); response != nil {
+			if choice, ok := response["direction"].(string); ok && choice == "left" {
+				direction = "left"
+			}
+		}
+		s.takeOverStalledBots()
+	}
+	if sheriff.IsAI && sheriff.AI().SpeechDirection(sheriff.View()) == "left" {
+		direction = "left"
+	}
 
-	return result, nil
+	s.game.Log(fmt.Sprintf("警长 %s 选择从 %s 方向开始发言", sheriff.Name, direction))
+	s.BroadcastMessage(map[string]interface{}{
+		"type":      "speech_direction",
+		"sheriff":   sheriff.ID,
+		"direction": direction,
+	})
+	return direction
 }
 
-// 停止服务器
-func (s *GameServer) Stop() {
-	if s.listener != nil {
-		s.listener.Close()
+// 处理一名玩家的发言回合，真人玩家可以多次发言或提前结束
+func (s *GameServer) HandleSpeech(speaker *Player, limit time.Duration) {
+	s.BroadcastMessage(map[string]interface{}{
+		"type":    "speech_turn",
+		"player":  speaker.ID,
+		"seconds": int(limit / time.Second),
+	})
+
+	if speaker.IsAI {
+		text, claims := speaker.AI().Speech(speaker.View())
+		s.RelaySpeech(speaker, text)
+		if len(claims) > 0 {
+			s.game.RecordClaim(speaker, speaker.Role.GetID(), claims)
+		}
+		return
+	}
+	s.collectSpeech(speaker, limit, func(text string) {
+		s.RelaySpeech(speaker, Raw(text))
+	})
+}
+
+// 在时限内接收真人玩家的发言并交给 relay 转发，玩家结束发言或超时后返回
+func (s *GameServer) collectSpeech(speaker *Player, limit time.Duration, relay func(text string)) {
+	idx := s.clientIndex(speaker)
+	if idx < 0 {
+		return
 	}
 
-	for _, client := range s.clients {
-		if client.conn != nil {
-			client.conn.Close()
+	s.setSpeaker(speaker)
+	defer s.setSpeaker(nil)
+
+	deadline := time.Now().Add(limit)
+	for {
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			s.game.Log(fmt.Sprintf("%s 发言时间结束", speaker.Name))
+			return
+		}
+
+		message, timedOut := s.receiveWithin(idx, remaining)
+		if timedOut {
+			s.game.Log(fmt.Sprintf("%s 发言时间结束", speaker.Name))
+			return
+		}
+		if message == nil {
+			return
+		}
+
+		switch message["type"] {
+		case "speech":
+			if text, ok := message["text"].(string); ok && text != "" {
+				relay(text)
+			}
+		case "end_speech":
+			s.game.Log(fmt.Sprintf("%s 结束发言", speaker.Name))
+			return
 		}
 	}
+}
 
-	s.running = false
+// 将发言转发给所有玩家
+func (s *GameServer) RelaySpeech(speaker *Player, text LocalText) {
+	s.game.Log(fmt.Sprintf("%s 发言: %s", speaker.Name, text.Render(DefaultLocale)))
+	s.BroadcastMessage(map[string]interface{}{
+		"type":   "speech",
+		"player": speaker.ID,
+		"text":   text,
+	})
 }
 
-// 广播消息给所有客户端
-func (s *GameServer) BroadcastMessage(message map[string]interface{}) {
-	for _, client := range s.clients {
-		err := json.NewEncoder(client.conn).Encode(message)
-		if err != nil {
-			s.game.Log(fmt.Sprintf("发送消息失败: %v", err))
-		}
+// 遗言阶段，放逐出局或符合规则的夜间死亡后进入，结束后回到打断前的流程
+func (s *GameServer) phaseLastWords() string {
+	seconds := s.game.Options.SpeechSeconds
+	if seconds <= 0 {
+		seconds = DefaultSpeechSeconds
+	}
+	for _, dead := range s.lastWords {
+		s.HandleLastWords(dead, time.Duration(seconds)*time.Second)
+	}
+	s.lastWords = nil
+
+	if s.phases.Previous == PhaseAnnounce {
+		return PhaseDiscussion
 	}
+	return ""
 }
 
-// 发送消息给指定客户端
-func (s *GameServer) SendMessage(message map[string]interface{}, index int) {
-	if index >= 0 && index < len(s.clients) {
-		err := json.NewEncoder(s.clients[index].conn).Encode(message)
-		if err != nil {
-			s.game.Log(fmt.Sprintf("发送消息失败: %v", err))
+// 处理一名死亡玩家的遗言
+func (s *GameServer) HandleLastWords(dead *Player, limit time.Duration) {
+	s.BroadcastMessage(map[string]interface{}{
+		"type":    "last_words_turn",
+		"player":  dead.ID,
+		"seconds": int(limit / time.Second),
+	})
+
+	if dead.IsAI {
+		text, claims := dead.AI().LastWords(dead.View())
+		s.RelayLastWords(dead, text)
+		if len(claims) > 0 {
+			s.game.RecordClaim(dead, dead.Role.GetID(), claims)
 		}
-	} else {
-		s.game.Log(fmt.Sprintf("客户端索引越界: %d，客户端数量: %d", index, len(s.clients)))
+		return
+	}
+
+	if idx := s.clientIndex(dead); idx >= 0 {
+		s.SendMessage(map[string]interface{}{
+			"type":    "last_words",
+			"seconds": int(limit / time.Second),
+		}, idx)
 	}
+	s.collectSpeech(dead, limit, func(text string) {
+		s.RelayLastWords(dead, Raw(text))
+	})
 }
 
-// 接收消息
-func (s *GameServer) ReceiveMessage(index int) map[string]interface{} {
-	if index >= 0 && index < len(s.clients) {
-		// 设置读取超时
-		s.clients[index].conn.SetReadDeadline(time.Now().Add(10 * time.Second))
-
-		var message map[string]interface{}
-		err := json.NewDecoder(s.clients[index].conn).Decode(&message)
+// 将遗言转发给所有玩家，包括已经出局的玩家
+func (s *GameServer) RelayLastWords(dead *Player, text LocalText) {
+	s.game.Log(fmt.Sprintf("%s 的遗言: %s", dead.Name, text.Render(DefaultLocale)))
+	s.BroadcastMessage(map[string]interface{}{
+		"type":   "last_words_speech",
+		"player": dead.ID,
+		"text":   text,
+	})
+}
 
-		// 重置超时
-		s.clients[index].conn.SetReadDeadline(time.Time{})
+// AI狼人即将被放逐时按策略选择是否自爆，白狼王自爆时带走一名玩家
+func (s *GameServer) aiExplosion() *explodeRequest {
+	leaders := s.game.topVoted()
+	if len(leaders) != 1 || !leaders[0].IsAI || !leaders[0].IsWolf() {
+		return nil
+	}
 
-		if err != nil {
-			s.game.Log(fmt.Sprintf("接收消息失败: %v", err))
-			return nil
+	wolf := leaders[0]
+	if !wolf.AI().Explode(wolf.View()) {
+		return nil
+	}
+	if wolf.IsWhiteWolfKing() {
+		target := ""
+		if actionResult := wolf.DayAction(s.game.Players); actionResult != nil {
+			target, _ = actionResult["target"].(string)
 		}
-		return message
+		return &explodeRequest{player: wolf, target: target}
 	}
-	return nil
+	return &explodeRequest{player: wolf}
 }
 
-// 发送游戏状态
-func (s *GameServer) SendGameStatus() {
-	for i, client := range s.clients {
-		player := client.player
-		if player == nil {
-			continue
+// 狼人自爆，白天立即结束，白狼王带走一名玩家
+func (s *GameServer) HandleExplode(req explodeRequest) {
+	wolf := req.player
+	s.game.Log(fmt.Sprintf("\n%s 自爆，白天立即结束", wolf.Name))
+
+	var target *Player
+	if wolf.IsWhiteWolfKing() {
+		targetID := req.target
+		if !wolf.IsAI && !s.validWolfKingTarget(wolf, targetID) {
+			if idx := s.clientIndex(wolf); idx >= 0 {
+				targetID = s.PlayerWolfKingTarget(idx, wolf)
+			}
+			s.takeOverStalledBots()
 		}
-
-		playersInfo := [][]interface{}{}
-		for _, p := range s.game.Players {
-			roleName := "未知"
-			if p == player || (p.IsWolf() && player.IsWolf()) {
-				roleName = p.Role.GetName()
+		if wolf.IsAI && !s.validWolfKingTarget(wolf, targetID) {
+			if actionResult := wolf.DayAction(s.game.Players); actionResult != nil {
+				targetID, _ = actionResult["target"].(string)
 			}
-			playersInfo = append(playersInfo, []interface{}{p.Name, roleName, p.Alive, p.Sheriff})
 		}
-
-		status := map[string]interface{}{
-			"type":      "game_status",
-			"role":      player.Role.GetName(),
-			"players":   playersInfo,
-			"day_count": s.game.DayCount,
+		if s.validWolfKingTarget(wolf, targetID) {
+			target = s.game.FindPlayer(targetID)
+		} else {
+			s.game.Log(fmt.Sprintf("白狼王 %s 没有带走任何人", wolf.Name))
 		}
-
-		s.SendMessage(status, i)
 	}
-}
-
-// 运行游戏
-func (s *GameServer) RunGameLoop() {
-	s.game.Log("=== 狼人杀游戏开始 ===")
 
-	gameOver := false
-	for !gameOver {
-		// 夜晚阶段
-		s.game.Log("\n=== 黑夜 ===")
-		s.HandleNightPhase()
-		s.game.FinishNight()
+	announcement := map[string]interface{}{
+		"type":   "self_destruct",
+		"player": wolf.ID,
+	}
+	event := GameEvent{Type: EventSelfDestruct, Actor: wolf.ID, Visibility: VisibilityPublic}
+	deaths := []*Player{wolf}
+	if target != nil {
+		s.game.Log(fmt.Sprintf("白狼王 %s 带走了 %s", wolf.Name, target.Name))
+		announcement["target"] = target.ID
+		event.Target = target.ID
+		deaths = append(deaths, target)
+	}
 
-		// 发送游戏状态更新
-		time.Sleep(1 * time.Second)
-		s.SendGameStatus()
+	s.game.Record(event)
+	s.game.KillPlayer(wolf, DeathByExplode)
+	if target != nil {
+		s.game.KillPlayer(target, DeathByWolfKing)
+	}
+	s.BroadcastMessage(announcement)
+	s.HandleDeaths(deaths)
+}
 
-		// 警长选举（只在第一天）
-		if s.game.Sheriff == nil && !s.game.SheriffElect {
-			s.game.Log("警长选举，玩家投票选举警长")
-			s.HandleSheriffElection()
-			s.game.SheriffElect = true
-		}
+// 判断白狼王带走的目标是否有效
+func (s *GameServer) validWolfKingTarget(wolf *Player, targetID string) bool {
+	target := s.game.FindPlayer(targetID)
+	return target != nil && target.Alive && target != wolf
+}
 
-		if s.game.Sheriff == nil && s.game.SheriffElect {
-			s.game.TransferSheriff()
+// 处理真人白狼王选择带走的目标
+func (s *GameServer) PlayerWolfKingTarget(playerIndex int, player *Player) string {
+	candidates := []string{}
+	for _, p := range s.game.Players {
+		if p.Alive && p != player {
+			candidates = append(candidates, p.ID)
 		}
+	}
 
-		// 白天阶段
-		s.game.Log("\n=== 白天 ===")
-		s.HandleDayPhase()
+	s.SendMessage(map[string]interface{}{
+		"type":       "wolf_king_target",
+		"candidates": candidates,
+	}, playerIndex)
 
-		// 发送游戏状态更新
-		time.Sleep(1 * time.Second)
-		s.SendGameStatus()
+	response := s.ReceiveMessage(playerIndex)
+	if response == nil {
+		return ""
+	}
+	targetID, _ := response["target"].(string)
+	return targetID
+}
 
-		winner := ""
-		if s.game.WinnerIsWerewolf {
-			winner = "狼人"
-		} else {
-			winner = "好人"
-		}
-		if s.game.CheckGameEnd() {
+// 结算死亡玩家的技能，被技能带走的玩家同样进入结算，返回本次结算的全部死亡玩家
+func (s *GameServer) HandleDeaths(deaths []*Player) []*Player {
+	handled := []*Player{}
+	for len(deaths) > 0 {
+		dead := deaths[0]
+		deaths = deaths[1:]
+		handled = append(handled, dead)
+
+		// 情侣一方死亡，另一方殉情
+		if lover := dead.Lover; lover != nil && lover.Alive {
+			s.game.Log(fmt.Sprintf("%s 的情侣 %s 殉情而死", dead.Name, lover.Name))
+			s.game.KillPlayer(lover, DeathByLove)
 			s.BroadcastMessage(map[string]interface{}{
-				"type":   "game_end",
-				"winner": winner,
+				"type":   "lover_died",
+				"player": lover.ID,
+				"lover":  dead.ID,
 			})
-			gameOver = true
+			deaths = append(deaths, lover)
 		}
-	}
-}
 
-// 处理警长选举
-func (s *GameServer) HandleSheriffElection() {
-	var wg sync.WaitGroup
+		if dead.Sheriff {
+			s.HandleBadgeTransfer(dead)
+		}
 
-	// 处理真人玩家投票
-	for i, client := range s.clients {
-		if client.player != nil && client.player.Alive {
-			wg.Add(1)
-			go func(idx int, p *Player) {
-				defer wg.Done()
-				s.PlayerSheriffVote(idx, p)
-			}(i, client.player)
+		if dead.IsHunter() {
+			if victim := s.HunterShoot(dead); victim != nil {
+				deaths = append(deaths, victim)
+			}
 		}
 	}
+	return handled
+}
 
-	// 处理AI玩家投票
-	validCandidates := []*Player{}
-	for _, p := range s.game.Players {
-		if p.Alive && p.IsAI {
-			validCandidates = append(validCandidates, p)
-		}
+// 猎人开枪带走一名玩家，返回被带走的玩家，放弃开枪时返回nil
+func (s *GameServer) HunterShoot(hunter *Player) *Player {
+	if hunter.Poisoned {
+		s.game.Log(fmt.Sprintf("猎人 %s 被毒死，无法开枪", hunter.Name))
+		return nil
+	}
+	if hunter.DeathCause == DeathByLove {
+		s.game.Log(fmt.Sprintf("猎人 %s 殉情而死，无法开枪", hunter.Name))
+		return nil
 	}
 
-	for _, voter := range validCandidates {
-		s.voteLock.Lock()
-		if len(validCandidates) > 0 {
-			target := validCandidates[rand.Intn(len(validCandidates))]
-			target.Votes++
-			s.game.Log(fmt.Sprintf("%s (%s) 投票给 %s", voter.Name, voter.Role.GetName(), target.Name))
+	// 没有回复的外部机器人由AI接管后补上这次开枪
+	targetID := ""
+	if !hunter.IsAI {
+		if idx := s.clientIndex(hunter); idx >= 0 {
+			targetID = s.PlayerHunterShoot(idx, hunter)
+		}
+		s.takeOverStalledBots()
+	}
+	if hunter.IsAI {
+		if actionResult := hunter.DayAction(s.game.Players); actionResult != nil {
+			targetID, _ = actionResult["target"].(string)
 		}
-		s.voteLock.Unlock()
 	}
 
-	// 等待所有投票完成
-	wg.Wait()
+	target := s.game.FindPlayer(targetID)
+	if target == nil || !target.Alive || target == hunter {
+		s.game.Log(fmt.Sprintf("猎人 %s 放弃开枪", hunter.Name))
+		return nil
+	}
 
-	// 选举警长
-	s.game.ElectSheriff()
+	s.game.Log(fmt.Sprintf("猎人 %s 开枪带走了 %s", hunter.Name, target.Name))
+	s.game.Record(GameEvent{Type: EventHunterShot, Actor: hunter.ID, Target: target.ID, Visibility: VisibilityPublic})
+	s.game.KillPlayer(target, DeathByHunter)
+	s.BroadcastMessage(map[string]interface{}{
+		"type":   "hunter_shot",
+		"hunter": hunter.ID,
+		"target": target.ID,
+	})
+	return target
 }
 
-// 处理玩家警长投票
-func (s *GameServer) PlayerSheriffVote(playerIndex int, player *Player) {
+// 处理真人猎人开枪，返回目标名称，跳过时返回空字符串
+func (s *GameServer) PlayerHunterShoot(playerIndex int, player *Player) string {
 	candidates := []string{}
 	for _, p := range s.game.Players {
-		if p.Alive {
-			candidates = append(candidates, p.Name)
+		if p.Alive && p != player {
+			candidates = append(candidates, p.ID)
 		}
 	}
 
 	s.SendMessage(map[string]interface{}{
-		"type":       "sheriff_election",
+		"type":       "hunter_shoot",
 		"candidates": candidates,
+		"can_skip":   true,
 	}, playerIndex)
 
 	response := s.ReceiveMessage(playerIndex)
-	if response != nil {
-		if targetName, ok := response["vote"].(string); ok {
-			s.voteLock.Lock()
-			for _, p := range s.game.Players {
-				if p.Name == targetName {
-					p.Votes++
-					s.game.Log(fmt.Sprintf("%s 投票给 %s", player.Name, targetName))
-					break
-				}
-			}
-			s.voteLock.Unlock()
-		}
+	if response == nil {
+		return ""
+	}
+	if skip, ok := response["skip"].(bool); ok && skip {
+		return ""
 	}
+	targetID, _ := response["target"].(string)
+	return targetID
 }
 
-// 处理玩家白天投票
-func (s *GameServer) PlayerDayVote(playerIndex int, player *Player) {
-	candidates := []string{}
+// 死亡的警长选择警徽继承人或撕毁警徽，结果公开宣布
+func (s *GameServer) HandleBadgeTransfer(sheriff *Player) {
+	candidates := []*Player{}
 	for _, p := range s.game.Players {
-		if p.Alive && p != player {
-			candidates = append(candidates, p.Name)
+		if p.Alive && p != sheriff {
+			candidates = append(candidates, p)
 		}
 	}
 
-	s.SendMessage(map[string]interface{}{
-		"type":       "day_vote",
-		"candidates": candidates,
-	}, playerIndex)
+	var heir *Player
+	if sheriff.IsAI {
+		heir = s.aiBadgeHeir(sheriff, candidates)
+	} else if idx := s.clientIndex(sheriff); idx >= 0 {
+		heir = s.PlayerBadgeTransfer(idx, sheriff, candidates)
+	}
 
-	response := s.ReceiveMessage(playerIndex)
-	if response != nil {
-		if targetName, ok := response["vote"].(string); ok {
-			s.voteLock.Lock()
-			for _, p := range s.game.Players {
-				if p.Name == targetName {
-					voteValue := 1.0
-					if player.Sheriff {
-						voteValue = 1.5
-					}
-					p.Votes += voteValue
-					s.game.Log(fmt.Sprintf("%s (%s) 投票给 %s", player.Name, player.Role.GetName(), p.Name))
-					break
-				}
-			}
-			s.voteLock.Unlock()
-		}
+	s.game.TransferSheriff(heir)
+	announcement := map[string]interface{}{
+		"type":    "badge_transfer",
+		"sheriff": sheriff.ID,
+		"torn":    heir == nil,
+	}
+	if heir != nil {
+		announcement["heir"] = heir.ID
 	}
+	s.BroadcastMessage(announcement)
 }
 
-// 处理夜晚阶段
-func (s *GameServer) HandleNightPhase() {
-	nightLock := sync.Mutex{}
-	var wg sync.WaitGroup
-
-	// 狼人阶段
-	processWolves := func() {
-		// 处理人类狼人
-		for i, client := range s.clients {
-			if client.player != nil && client.player.Alive && client.player.IsWolf() {
-				wg.Add(1)
-				go func(idx int, p *Player) {
-					defer wg.Done()
-					s.PlayerNightAction(idx, p, "werewolf")
-				}(i, client.player)
-			}
-		}
-		wg.Wait()
+// AI警长按策略选择警徽继承人，返回nil表示撕毁警徽
+func (s *GameServer) aiBadgeHeir(sheriff *Player, candidates []*Player) *Player {
+	heir := s.game.FindPlayer(sheriff.AI().BadgeHeir(sheriff.View(), playerIDs(candidates)))
+	if !containsPlayer(candidates, heir) {
+		return nil
 	}
+	return heir
+}
 
-	// 女巫阶段
-	processWitches := func() {
-		// 处理AI女巫
-		for _, p := range s.game.Players {
-			if p.Alive && p.IsWitch() && p.IsAI {
-				actionResult := p.NightAction(s.game.Players)
-				if actionResult != nil {
-					nightLock.Lock()
-					s.game.Log(fmt.Sprintf("女巫 %s (AI) 执行行动: %v", p.Name, actionResult))
-					nightLock.Unlock()
-				}
-			}
-		}
+// 处理真人警长移交警徽，超时或无效选择时按 BadgeDefault 处理，返回nil表示撕毁警徽
+func (s *GameServer) PlayerBadgeTransfer(playerIndex int, sheriff *Player, candidates []*Player) *Player {
+	s.SendMessage(map[string]interface{}{
+		"type":       "badge_transfer",
+		"candidates": playerIDs(candidates),
+		"can_tear":   true,
+	}, playerIndex)
 
-		// 处理人类女巫
-		for i, client := range s.clients {
-			if client.player != nil && client.player.Alive && client.player.IsWitch() {
-				wg.Add(1)
-				go func(idx int, p *Player) {
-					defer wg.Done()
-					s.PlayerNightAction(idx, p, "witch")
-				}(i, client.player)
-			}
+	response := s.ReceiveMessage(playerIndex)
+	if response != nil {
+		if tear, ok := response["tear"].(bool); ok && tear {
+			return nil
 		}
-		wg.Wait()
-	}
-
-	// 预言家阶段
-	processSeers := func() {
-		// 处理AI预言家
-		for _, p := range s.game.Players {
-			if p.Alive && p.IsSeer() && p.IsAI {
-				actionResult := p.NightAction(s.game.Players)
-				if actionResult != nil {
-					nightLock.Lock()
-					s.game.Log(fmt.Sprintf("预言家 %s (AI) 执行行动: %v", p.Name, actionResult))
-					nightLock.Unlock()
+		if targetID, ok := response["target"].(string); ok {
+			for _, p := range candidates {
+				if p.ID == targetID {
+					return p
 				}
 			}
 		}
+	}
 
-		// 处理人类预言家
-		for i, client := range s.clients {
-			if client.player != nil && client.player.Alive && client.player.IsSeer() {
-				wg.Add(1)
-				go func(idx int, p *Player) {
-					defer wg.Done()
-					s.PlayerNightAction(idx, p, "seer")
-				}(i, client.player)
-			}
-		}
-		wg.Wait()
+	// 没有回复的外部机器人由AI接管后补上这次选择，不按默认规则处理
+	if s.takeOverStalledBots(); sheriff.IsAI {
+		return s.aiBadgeHeir(sheriff, candidates)
 	}
 
-	// 按顺序执行各角色行动
-	s.game.NightActions() // 处理狼人投票
-	processWolves()
-	processWitches()
-	processSeers()
+	s.game.Log(fmt.Sprintf("警长 %s 没有做出有效选择，按默认规则 %s 处理", sheriff.Name, s.game.Options.BadgeDefault))
+	if s.game.Options.BadgeDefault == BadgeDefaultRandom && len(candidates) > 0 {
+		return candidates[s.game.rng.Intn(len(candidates))]
+	}
+	return nil
 }
 
-func (s *GameServer) HandleDayPhase() {
-	s.game.DayActions()
-
-	var wg sync.WaitGroup
-
-	// 处理人类玩家投票 - 只有活着的玩家才能投票
+// 查找玩家对应的客户端索引，AI玩家返回-1
+func (s *GameServer) clientIndex(player *Player) int {
 	for i, client := range s.clients {
-		if client.player != nil && client.player.Alive {
-			wg.Add(1)
-			go func(idx int, p *Player) {
-				defer wg.Done()
-				s.PlayerDayVote(idx, p)
-			}(i, client.player)
+		if client.player == player {
+			return i
 		}
 	}
+	return -1
+}
 
-	// 处理AI玩家投票 - 只有活着的玩家才能投票
-	validCandidates := []*Player{}
-	for _, p := range s.game.Players {
-		if p.Alive {
-			validCandidates = append(validCandidates, p)
+func (s *GameServer) PlayerNightAction(playerIndex int, player *Player, roleType string) {
+	switch roleType {
+	case "cupid":
+		// 丘比特行动
+		cupid, ok := player.Role.(*Cupid)
+		if !ok || cupid.Linked {
+			return
 		}
-	}
 
-	for _, voter := range s.game.Players {
-		if voter.IsAI && voter.Alive {
-			s.voteLock.Lock()
-			voteCandidates := []*Player{}
-			for _, p := range validCandidates {
-				if p != voter {
-					voteCandidates = append(voteCandidates, p)
-				}
-			}
-
-			if len(voteCandidates) > 0 {
-				target := voteCandidates[rand.Intn(len(voteCandidates))]
-				voteValue := 1.0
-				if voter.Sheriff {
-					voteValue = 1.5
-				}
-				target.Votes += voteValue
-				s.game.Log(fmt.Sprintf("%s (%s) 投票给 %s", voter.Name, voter.Role.GetName(), target.Name))
-			} else {
-				s.game.Log(fmt.Sprintf("%s 没有可投票的目标", voter.Name))
+		candidates := []string{}
+		for _, p := range s.game.Players {
+			if p.Alive {
+				candidates = append(candidates, p.ID)
 			}
-			s.voteLock.Unlock()
 		}
-	}
 
-	// 等待所有投票完成
-	wg.Wait()
+		s.SendMessage(map[string]interface{}{
+			"type":       "night_action",
+			"action":     "cupid",
+			"candidates": candidates,
+		}, playerIndex)
 
-	// 执行投票结果
-	s.game.Vote()
-}
+		s.submitNightResponse(player, "cupid", s.ReceiveMessage(playerIndex))
+
+	case "guard":
+		// 守卫行动
+		guard, ok := player.Role.(*Guard)
+		if !ok {
+			return
+		}
 
-func (s *GameServer) PlayerNightAction(playerIndex int, player *Player, roleType string) {
-	switch roleType {
-	case "werewolf":
 		candidates := []string{}
 		for _, p := range s.game.Players {
-			if p.Alive && !p.IsWolf() {
-				candidates = append(candidates, p.Name)
+			if p.Alive && p.ID != guard.LastProtected {
+				candidates = append(candidates, p.ID)
 			}
 		}
 
 		s.SendMessage(map[string]interface{}{
-			"type":       "night_action",
-			"action":     "werewolf",
-			"candidates": candidates,
+			"type":           "night_action",
+			"action":         "guard",
+			"candidates":     candidates,
+			"last_protected": guard.LastProtected,
 		}, playerIndex)
 
+		// 没有回复的外部机器人稍后由AI接管并补上守护，不按空守处理
 		response := s.ReceiveMessage(playerIndex)
-		if response != nil {
-			if targetName, ok := response["target"].(string); ok {
-				s.voteLock.Lock()
-				s.game.HumanWolfVotes[targetName]++
-				s.game.Log(fmt.Sprintf("狼人 %s (真人) 选择击杀 %s", player.Name, targetName))
-				s.voteLock.Unlock()
-			}
+		if !s.submitNightResponse(player, "guard", response) && !s.stalled(s.clients[playerIndex]) {
+			s.game.Log(fmt.Sprintf("守卫 %s (真人) 的守护目标无效，视为空守", player.Name))
+			s.game.SubmitNightAction(player, ActionGuard, "")
 		}
 
 	case "witch":
@@ -1407,11 +5389,14 @@ func (s *GameServer) PlayerNightAction(playerIndex int, player *Player, roleType
 		}
 
 		// 准备可用操作和目标
-		deadTarget := s.game.WolfKillTarget
+		deadPlayers := []string{}
+		if victim := s.game.ShowWitchVictim(player); victim != "" {
+			deadPlayers = append(deadPlayers, victim)
+		}
 		alivePlayers := []string{}
 		for _, p := range s.game.Players {
 			if p.Alive && p != player {
-				alivePlayers = append(alivePlayers, p.Name)
+				alivePlayers = append(alivePlayers, p.ID)
 			}
 		}
 
@@ -1421,38 +5406,20 @@ func (s *GameServer) PlayerNightAction(playerIndex int, player *Player, roleType
 			"action":        "witch",
 			"has_poison":    witch.HasPoison,
 			"has_antidote":  witch.HasAntidote,
-			"dead_players":  []string{deadTarget},
+			"can_save":      s.game.CanWitchSave(player),
+			"one_potion":    !s.game.Options.Witch.BothPotions,
+			"dead_players":  deadPlayers,
 			"alive_players": alivePlayers,
 		}, playerIndex)
 
-		response := s.ReceiveMessage(playerIndex)
-		if response != nil {
-			// 处理解药
-			if saveTarget, ok := response["save"].(string); ok && witch.HasAntidote && saveTarget == s.game.WolfKillTarget {
-				s.game.Antidote = true
-				witch.HasAntidote = false
-				s.game.Log(fmt.Sprintf("女巫 %s (真人) 使用解药救活 %s", player.Name, saveTarget))
-			}
-
-			// 处理毒药
-			if poisonTarget, ok := response["poison"].(string); ok && witch.HasPoison {
-				for _, p := range s.game.Players {
-					if p.Name == poisonTarget && p.Alive {
-						witch.HasPoison = false
-						witch.PoisonedTarget = poisonTarget
-						s.game.Log(fmt.Sprintf("女巫 %s (真人) 对 %s 使用了毒药", player.Name, poisonTarget))
-						break
-					}
-				}
-			}
-		}
+		s.submitNightResponse(player, "witch", s.ReceiveMessage(playerIndex))
 
 	case "seer":
 		// 预言家行动
 		candidates := []string{}
 		for _, p := range s.game.Players {
 			if p.Alive && p != player {
-				candidates = append(candidates, p.Name)
+				candidates = append(candidates, p.ID)
 			}
 		}
 
@@ -1462,37 +5429,56 @@ func (s *GameServer) PlayerNightAction(playerIndex int, player *Player, roleType
 			"candidates": candidates,
 		}, playerIndex)
 
-		response := s.ReceiveMessage(playerIndex)
-		if response != nil {
-			if targetName, ok := response["target"].(string); ok {
-				var target *Player
-				for _, p := range s.game.Players {
-					if p.Name == targetName {
-						target = p
-						break
-					}
-				}
+		s.submitNightResponse(player, "seer", s.ReceiveMessage(playerIndex))
+	}
+}
+
+// 为外部机器人附加该玩家视角的局面，放在单独的 ai_view 字段中，不覆盖消息原有的 knowledge
+func (s *GameServer) botMessage(client *ClientConnection, message map[string]interface{}) map[string]interface{} {
+	withView := map[string]interface{}{}
+	for k, v := range message {
+		withView[k] = v
+	}
+	withView["ai_view"] = client.player.View()
+	return withView
+}
 
-				if target != nil {
-					role := "狼人"
-					if !target.IsWolf() {
-						role = "好人"
-					}
+// 标记没有响应的外部机器人；收集行动的过程中不改变座位的控制方，避免同一座位既按AI又按真人计入
+func (s *GameServer) markStalled(client *ClientConnection) {
+	s.stateLock.Lock()
+	client.stalled = true
+	s.stateLock.Unlock()
+}
 
-					s.SendMessage(map[string]interface{}{
-						"type":   "seer_result",
-						"action": "seer",
-						"target": targetName,
-						"result": role,
-					}, playerIndex)
+// 外部机器人是否因没有回复被标记，等待游戏主流程接管
+func (s *GameServer) stalled(client *ClientConnection) bool {
+	s.stateLock.Lock()
+	defer s.stateLock.Unlock()
+	return client.stalled
+}
 
-					s.game.Log(fmt.Sprintf("预言家 %s 查验 %s 的身份是 %s", player.Name, targetName, role))
-				}
-			}
+// 由内置AI接管被标记的外部机器人，在阶段之间和每轮收集结束后调用，调用方随后让AI补上没有回复的行动
+func (s *GameServer) takeOverStalledBots() {
+	for _, client := range s.clients {
+		s.stateLock.Lock()
+		stalled := client.stalled
+		client.stalled = false
+		s.stateLock.Unlock()
+		if stalled && client.player != nil && !client.player.IsAI {
+			s.takeOverBot(client)
 		}
 	}
 }
 
+// 由内置AI接管外部机器人的座位，只能在游戏主流程中没有并发收集行动时调用
+func (s *GameServer) takeOverBot(client *ClientConnection) {
+	player := client.player
+	player.IsAI = true
+	player.TakenOver = true
+	client.pending = nil
+	s.game.Log(fmt.Sprintf("机器人 %s 没有响应，由内置AI (%s) 接管", player.Name, player.AI().Name()))
+}
+
 // ============ 游戏管理器定义 ============
 
 // GameManager 管理多个游戏实例
@@ -1513,7 +5499,7 @@ func NewGameManager(basePort int) *GameManager {
 }
 
 // 创建新游戏
-func (gm *GameManager) StartNewGame(numRealPlayers, numAIPlayers int) (int, error) {
+func (gm *GameManager) StartNewGame(numRealPlayers, numAIPlayers int, opts GameOptions) (int, error) {
 	gm.mu.Lock()
 	gameID := gm.nextGameID
 	gm.nextGameID++
@@ -1537,7 +5523,7 @@ func (gm *GameManager) StartNewGame(numRealPlayers, numAIPlayers int) (int, erro
 		server := NewGameServer()
 		instance.Server = server
 
-		result, err := server.Start("localhost", port, numRealPlayers, numAIPlayers)
+		result, err := server.Start("localhost", port, numRealPlayers, numAIPlayers, opts)
 
 		// 游戏结束后更新状态
 		gm.mu.Lock()
@@ -1609,7 +5595,7 @@ func (gm *GameManager) WaitForGameToComplete(gameID int, timeout time.Duration)
 }
 
 // 运行多个游戏
-func (gm *GameManager) RunMultipleGames(count, numRealPlayers, numAIPlayers int, timeout time.Duration) []*GameResult {
+func (gm *GameManager) RunMultipleGames(count, numRealPlayers, numAIPlayers int, opts GameOptions, timeout time.Duration) []*GameResult {
 	var wg sync.WaitGroup
 	results := make([]*GameResult, count)
 
@@ -1618,7 +5604,7 @@ func (gm *GameManager) RunMultipleGames(count, numRealPlayers, numAIPlayers int,
 		go func(index int) {
 			defer wg.Done()
 
-			gameID, err := gm.StartNewGame(numRealPlayers, numAIPlayers)
+			gameID, err := gm.StartNewGame(numRealPlayers, numAIPlayers, opts)
 			if err != nil {
 				results[index] = &GameResult{Error: err}
 				return
@@ -1638,12 +5624,18 @@ func (gm *GameManager) RunMultipleGames(count, numRealPlayers, numAIPlayers int,
 	return results
 }
 
+// 生成随机的上帝视角口令
+func newGodToken() string {
+	buf := make([]byte, 8)
+	if _, err := crand.Read(buf); err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 36)
+	}
+	return hex.EncodeToString(buf)
+}
+
 // ============ 主函数 ============
 
 func main() {
-	// 设置随机种子
-	rand.Seed(time.Now().UnixNano())
-
 	// 创建游戏管理器（基础端口5100用于TCP游戏服务器）
 	manager := NewGameManager(5100)
 
@@ -1667,12 +5659,81 @@ func main() {
 		if req.RealPlayers < 0 {
 			req.RealPlayers = 0
 		}
+		board, err := GetRoleBoard(req.Board)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		winCondition, err := ParseWinCondition(req.WinCondition)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		lastWords, err := ParseLastWordsRule(req.LastWords)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		badgeDefault, err := ParseBadgeDefault(req.BadgeDefault)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		selfSave, err := ParseSelfSaveRule(req.WitchSelfSave)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		aiStrategy, err := ParseAIStrategy(req.AIStrategy)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		for _, name := range req.AIStrategies {
+			if name == "" {
+				continue
+			}
+			if _, err := ParseAIStrategy(name); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
 		if req.AIPlayers <= 0 {
-			req.AIPlayers = 6 // 至少需要6个AI玩家以保证游戏角色分配
+			if board.PlayerCount > 0 {
+				req.AIPlayers = board.PlayerCount - req.RealPlayers // 固定人数的板子由AI补足
+			} else {
+				req.AIPlayers = 6 // 至少需要6个AI玩家以保证游戏角色分配
+			}
+		}
+		if _, err := board.RolesFor(req.RealPlayers + req.AIPlayers); err != nil {
+			http.Error(w, fmt.Sprintf("板子与玩家人数不匹配: %v", err), http.StatusBadRequest)
+			return
+		}
+
+		// 未指定上帝视角口令时随机生成，通过响应返回给创建者
+		godToken := req.GodToken
+		if godToken == "" {
+			godToken = newGodToken()
 		}
 
 		// 创建新游戏
-		gameID, err := manager.StartNewGame(req.RealPlayers, req.AIPlayers)
+		gameID, err := manager.StartNewGame(req.RealPlayers, req.AIPlayers, GameOptions{
+			Board:         board.Name,
+			WinCondition:  winCondition,
+			Seed:          req.Seed,
+			SpeechSeconds: req.SpeechSeconds,
+			WolfSeconds:   req.WolfSeconds,
+			LastWords:     lastWords,
+			BadgeDefault:  badgeDefault,
+			Witch: WitchRules{
+				SelfSave:    selfSave,
+				BothPotions: req.WitchBothPotions,
+				SeeVictim:   req.WitchSeeVictim,
+			},
+			AIStrategy:   aiStrategy,
+			AIStrategies: req.AIStrategies,
+			GodToken:     godToken,
+		})
 		if err != nil {
 			http.Error(w, fmt.Sprintf("创建游戏失败: %v", err), http.StatusInternalServerError)
 			return
@@ -1687,8 +5748,9 @@ func main() {
 
 		// 返回游戏信息
 		resp := CreateGameResponse{
-			GameID: gameID,
-			Port:   instance.Port,
+			GameID:   gameID,
+			Port:     instance.Port,
+			GodToken: godToken,
 		}
 
 		w.Header().Set("Content-Type", "application/json")
@@ -1697,8 +5759,30 @@ func main() {
 			return
 		}
 
-		log.Printf("游戏 #%d 已创建: 真实玩家=%d, AI玩家=%d, 端口=%d\n",
-			gameID, req.RealPlayers, req.AIPlayers, instance.Port)
+		log.Printf("游戏 #%d 已创建: 真实玩家=%d, AI玩家=%d, 板子=%s, 端口=%d\n",
+			gameID, req.RealPlayers, req.AIPlayers, board.Name, instance.Port)
+	})
+
+	// 处理板子列表请求
+	http.HandleFunc("/boards", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "仅支持GET请求", http.StatusMethodNotAllowed)
+			return
+		}
+
+		boards := make([]*RoleBoard, 0, len(roleBoards))
+		for _, board := range roleBoards {
+			boards = append(boards, board)
+		}
+		sort.Slice(boards, func(i, j int) bool {
+			return boards[i].Name < boards[j].Name
+		})
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(boards); err != nil {
+			http.Error(w, "响应编码失败", http.StatusInternalServerError)
+			return
+		}
 	})
 
 	// 处理游戏状态请求
@@ -1737,6 +5821,37 @@ func main() {
 		}
 	})
 
+	// 处理游戏事件请求，player 参数指定玩家视角，为空时返回上帝视角，只对已结束的游戏开放
+	http.HandleFunc("/game_events/", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "仅支持GET请求", http.StatusMethodNotAllowed)
+			return
+		}
+
+		path := r.URL.Path[len("/game_events/"):]
+		gameID, err := strconv.Atoi(path)
+		if err != nil {
+			http.Error(w, "游戏ID格式无效", http.StatusBadRequest)
+			return
+		}
+
+		isRunning, result, err := manager.GetGameStatus(gameID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		if isRunning || result == nil {
+			http.Error(w, "游戏尚未结束", http.StatusConflict)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(result.EventsFor(r.URL.Query().Get("player"))); err != nil {
+			http.Error(w, "响应编码失败", http.StatusInternalServerError)
+			return
+		}
+	})
+
 	// 处理游戏列表请求
 	http.HandleFunc("/games", func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodGet {
@@ -1836,6 +5951,7 @@ func main() {
 	log.Printf("  - POST /create_game - 创建新游戏\n")
 	log.Printf("  - GET /game_status/{id} - 获取游戏状态\n")
 	log.Printf("  - GET /games - 获取游戏列表\n")
+	log.Printf("  - GET /boards - 获取可选板子\n")
 	log.Printf("  - POST /stop_game/{id} - 停止游戏\n")
 
 	if err := http.ListenAndServe(serverAddr, nil); err != nil {